@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// alerter posts a JSON payload to the --alert-webhook once a target failed --alert-after times in a row.
+// Alerts for the same target are sent at most once every --alert-interval.
+// It is only used by the main goroutine, except for sending the alerts.
+type alerter struct {
+	url       string
+	client    *http.Client
+	streaks   map[string]uint
+	lastAlert map[string]time.Time
+	wg        sync.WaitGroup
+}
+
+func newAlerter(webhookUrl string) *alerter {
+	return &alerter{
+		url:       webhookUrl,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		streaks:   map[string]uint{},
+		lastAlert: map[string]time.Time{},
+	}
+}
+
+// alertPayload is the JSON payload of an alert.
+// Text and Content contain a readable message, so that Slack and Discord webhooks can be used directly.
+type alertPayload struct {
+	Text                string   `json:"text"`
+	Content             string   `json:"content"`
+	Target              string   `json:"target"`
+	Error               string   `json:"error"`
+	Timestamp           string   `json:"timestamp"`
+	ConsecutiveFailures uint     `json:"consecutive_failures"`
+	Requests            uint     `json:"requests"`
+	Failed              uint     `json:"failed"`
+	Average             *float64 `json:"avg_ms"`
+	Percentile95        *float64 `json:"p95_ms"`
+}
+
+// observe records the result of a request and sends an alert in the background if needed
+func (a *alerter) observe(statistics *Statistics, errMsg string, summary *summary) {
+	target := statistics.Target
+
+	if errMsg == "" {
+		a.streaks[target] = 0
+		return
+	}
+
+	a.streaks[target]++
+
+	if a.streaks[target] < alertAfter || time.Since(a.lastAlert[target]) < alertInterval {
+		return
+	}
+
+	a.lastAlert[target] = time.Now()
+
+	payload := &alertPayload{
+		Target:              target,
+		Error:               errMsg,
+		Timestamp:           statistics.Start.Format(time.RFC3339Nano),
+		ConsecutiveFailures: a.streaks[target],
+		Requests:            summary.requests,
+		Failed:              summary.failed,
+	}
+
+	payload.Text = fmt.Sprintf("httping: %s failed %d times in a row: %s", target, payload.ConsecutiveFailures, errMsg)
+	payload.Content = payload.Text
+
+	if s := summary.totals.Stats(); s.Samples > 0 {
+		payload.Average = &s.Average
+		payload.Percentile95 = &s.Percentile95
+	}
+
+	a.wg.Add(1)
+
+	go func() {
+		defer a.wg.Done()
+
+		if err := a.send(payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to send alert: %s\n", err)
+		}
+	}()
+}
+
+func (a *alerter) send(payload *alertPayload) error {
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Wait waits until all alerts have been sent
+func (a *alerter) Wait() {
+	a.wg.Wait()
+}
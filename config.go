@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configOption is a single option of the config file, with one value for every occurrence of a repeatable flag
+type configOption struct {
+	name   string
+	values []string
+	line   int
+}
+
+// conflictingOptions are the pairs of flags that cannot be combined. If one of them is given on the command line,
+// the other one is ignored in the config file, so that the command line silently takes precedence.
+var conflictingOptions = [][2]string{
+	{"once", "count"},
+	{"once", "success-count"},
+	{"count", "success-count"},
+	{"interval", "delay"},
+	{"interval", "rate"},
+	{"interval", "jitter"},
+	{"interval", "flood"},
+	{"interval", "delay-ramp"},
+	{"flood", "delay"},
+	{"flood", "rate"},
+	{"flood", "delay-ramp"},
+	{"rate", "delay-ramp"},
+	{"raw-request", "method"},
+	{"raw-request", "body"},
+	{"raw-request", "body-file"},
+	{"body", "body-file"},
+	{"user", "bearer"},
+	{"ipv4", "ipv6"},
+	{"dns-only", "connect-only"},
+	{"no-download", "expect-body"},
+	{"histogram", "stream-stats"},
+}
+
+// overriddenByCommandLine reports whether the option of the config file conflicts with a flag given on the command line.
+// --check on the command line also overrides the count of the config file, so that it sends a single request.
+func overriddenByCommandLine(name string, commandLine map[string]bool) bool {
+	if name == "count" && commandLine["check"] {
+		return true
+	}
+
+	for _, pair := range conflictingOptions {
+		if (pair[0] == name && commandLine[pair[1]]) || (pair[1] == name && commandLine[pair[0]]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyConfig sets every flag from the config file that was not given on the command line and does not conflict with
+// one that was. The URLs in the urls option are only used if no URLs were given on the command line.
+func applyConfig(flags *flag.FlagSet, path string) error {
+	options, err := readConfig(path)
+
+	if err != nil {
+		return err
+	}
+
+	// Setting a flag marks it as changed, so the flags of the command line are recorded first
+	commandLine := make(map[string]bool)
+
+	flags.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+
+	for _, option := range options {
+		if option.name == "urls" {
+			if len(targetUrls) == 0 {
+				targetUrls = option.values
+			}
+			continue
+		}
+
+		if option.name == "config" || flags.Lookup(option.name) == nil {
+			return fmt.Errorf("line %d: unknown option: %s", option.line, option.name)
+		}
+
+		// Command-line flags take precedence
+		if commandLine[option.name] || overriddenByCommandLine(option.name, commandLine) {
+			continue
+		}
+
+		for _, value := range option.values {
+			if err := flags.Set(option.name, value); err != nil {
+				return fmt.Errorf("line %d: %s: %w", option.line, option.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readConfig reads the options from a TOML (.toml) or YAML (.yaml, .yml) file.
+// Only top-level keys with scalar or list values are supported, as every option corresponds to a flag.
+func readConfig(path string) ([]*configOption, error) {
+	var separator string
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		separator = "="
+	case ".yaml", ".yml":
+		separator = ":"
+	default:
+		return nil, errors.New("unknown config file format, expected .toml, .yaml or .yml")
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var options []*configOption
+	var current *configOption
+
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+
+		if line == "" || line == "---" {
+			continue
+		}
+
+		// YAML block list item, belonging to the previous key
+		if item, found := strings.CutPrefix(line, "- "); found && separator == ":" {
+			if current == nil {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNumber)
+			}
+
+			value, err := parseConfigScalar(item)
+
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			current.values = append(current.values, value)
+			continue
+		}
+
+		key, value, found := strings.Cut(line, separator)
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if !found || key == "" || strings.HasPrefix(key, "[") {
+			return nil, fmt.Errorf("line %d: expected \"key %s value\"", lineNumber, separator)
+		}
+
+		current = &configOption{name: key, line: lineNumber}
+		options = append(options, current)
+
+		// An empty YAML value is followed by a block list
+		if value == "" && separator == ":" {
+			continue
+		}
+
+		// Lists may span multiple lines
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && scanner.Scan() {
+			lineNumber++
+			value += " " + strings.TrimSpace(stripComment(scanner.Text()))
+		}
+
+		if strings.HasPrefix(value, "[") {
+			current.values, err = parseConfigList(value)
+		} else {
+			value, err = parseConfigScalar(value)
+			current.values = []string{value}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+
+	return options, scanner.Err()
+}
+
+// stripComment removes a # comment, which must be at the start of the line or after whitespace and not inside quotes
+func stripComment(line string) string {
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// parseConfigList parses a list of scalars, e.g. ["a", "b"]
+func parseConfigList(s string) ([]string, error) {
+	s = strings.TrimSpace(s[1 : len(s)-1])
+
+	var items []string
+	var quote byte
+	escaped := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+
+	// Allow a trailing comma
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+
+	values := make([]string, len(items))
+
+	for i, item := range items {
+		value, err := parseConfigScalar(strings.TrimSpace(item))
+
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// parseConfigScalar parses a string ("double" with escapes, 'single' without), number or boolean
+func parseConfigScalar(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'"):
+		return "", fmt.Errorf("unterminated string: %s", s)
+	default:
+		return s, nil
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	flag "github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeConfig writes the config file with the given name (and therefore format) to a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string][]string
+	}{
+		{
+			"config.toml",
+			"# Comment\ncount = 10 # Trailing comment\nmethod = \"POST\"\nbody = 'raw \\n # not a comment'\nheader = [\"A: 1\", 'B: #2',\n  \"C: \\\"3\\\"\",]\n",
+			map[string][]string{
+				"count":  {"10"},
+				"method": {"POST"},
+				"body":   {`raw \n # not a comment`},
+				"header": {"A: 1", "B: #2", `C: "3"`},
+			},
+		},
+		{
+			"config.yaml",
+			"---\ncount: 10\ndelay: 2s # Comment\nuser: \"a:b\"\nheader:\n  - \"A: 1\"\n  - B: 2\nurls: [https://example.com/#fragment]\n",
+			map[string][]string{
+				"count":  {"10"},
+				"delay":  {"2s"},
+				"user":   {"a:b"},
+				"header": {"A: 1", "B: 2"},
+				"urls":   {"https://example.com/#fragment"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		options, err := readConfig(writeConfig(t, test.name, test.content))
+
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if len(options) != len(test.expected) {
+			t.Errorf("%s: expected %d options, got %d", test.name, len(test.expected), len(options))
+		}
+
+		for _, option := range options {
+			if expected := test.expected[option.name]; !slices.Equal(option.values, expected) {
+				t.Errorf("%s: %s: expected %q, got %q", test.name, option.name, expected, option.values)
+			}
+		}
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"config.json", "{}", "unknown config file format, expected .toml, .yaml or .yml"},
+		{"config.toml", "count 10", `line 1: expected "key = value"`},
+		{"config.toml", "[section]", `line 1: expected "key = value"`},
+		{"config.yaml", "- item", "line 1: list item without a key"},
+		{"config.yaml", "method: \"POST", `line 1: unterminated string: "POST`},
+	}
+
+	for _, test := range tests {
+		_, err := readConfig(writeConfig(t, test.name, test.content))
+
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s %q: expected %q, got %v", test.name, test.content, test.err, err)
+		}
+	}
+}
+
+// newTestFlags returns a flag set with a few of the flags of httping, parsed from the given command line
+func newTestFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+
+	var n uint
+	var d time.Duration
+
+	flags := flag.NewFlagSet("httping", flag.ContinueOnError)
+	flags.UintVarP(&n, "count", "n", 0, "")
+	flags.Bool("once", false, "")
+	flags.Bool("check", false, "")
+	flags.DurationVar(&d, "delay", time.Second, "")
+	flags.Duration("interval", 0, "")
+	flags.Bool("flood", false, "")
+	flags.String("method", "GET", "")
+	flags.StringArray("header", nil, "")
+
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return flags
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		args     []string
+		config   string
+		expected map[string]string
+		ignored  []string
+	}{
+		// Options of the file are used if they are not given on the command line
+		{nil, "count = 3\nmethod = \"HEAD\"", map[string]string{"count": "3", "method": "HEAD"}, nil},
+		{[]string{"--count", "5"}, "count = 3\nmethod = \"HEAD\"", map[string]string{"count": "5", "method": "HEAD"}, nil},
+		{[]string{"--header", "A: 1"}, "header = [\"B: 2\"]", map[string]string{"header": "[A: 1]"}, nil},
+
+		// Options of the file that conflict with the command line are ignored
+		{[]string{"--once"}, "count = 3", map[string]string{"count": "0"}, []string{"count"}},
+		{[]string{"--check"}, "count = 3", map[string]string{"count": "0"}, []string{"count"}},
+		{[]string{"--interval", "1s"}, "delay = \"2s\"", map[string]string{"delay": "1s"}, []string{"delay"}},
+		{[]string{"--flood"}, "delay = \"2s\"\ncount = 3", map[string]string{"delay": "1s", "count": "3"}, []string{"delay"}},
+
+		// Conflicts within the file are left to the validation
+		{nil, "once = true\ncount = 3", map[string]string{"once": "true", "count": "3"}, nil},
+	}
+
+	for _, test := range tests {
+		flags := newTestFlags(t, test.args...)
+
+		if err := applyConfig(flags, writeConfig(t, "config.toml", test.config)); err != nil {
+			t.Errorf("%v %q: %s", test.args, test.config, err)
+			continue
+		}
+
+		for name, expected := range test.expected {
+			if actual := flags.Lookup(name).Value.String(); actual != expected {
+				t.Errorf("%v %q: expected %s=%s, got %s", test.args, test.config, name, expected, actual)
+			}
+		}
+
+		// The conflict checks of main() must not see the ignored options as changed
+		for _, name := range test.ignored {
+			if flags.Changed(name) {
+				t.Errorf("%v %q: expected %s to be ignored", test.args, test.config, name)
+			}
+		}
+	}
+}
+
+func TestApplyConfigUnknownOption(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{"count = 1\nunknown = 1", "line 2: unknown option: unknown"},
+		{"config = \"other.toml\"", "line 1: unknown option: config"},
+		{"count = \"x\"", `line 1: count: invalid argument "x" for "-n, --count" flag: strconv.ParseUint: parsing "x": invalid syntax`},
+	}
+
+	for _, test := range tests {
+		err := applyConfig(newTestFlags(t), writeConfig(t, "config.toml", test.config))
+
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: expected %q, got %v", test.config, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newCookieJar returns a cookie jar containing the cookies from the given Netscape cookie file, if any
+func newCookieJar(cookieFile string) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cookieFile != "" {
+		err = loadCookieFile(jar, cookieFile)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return jar, nil
+}
+
+// loadCookieFile adds the cookies from a Netscape cookie file (as written by curl and browser extensions) to the jar.
+// Every line contains the domain, whether subdomains match, path, secure, expiry (Unix time), name and value separated by tabs.
+func loadCookieFile(jar http.CookieJar, path string) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// HttpOnly cookies are prefixed, as lines starting with # are comments otherwise
+		line, httpOnly := strings.CutPrefix(line, "#HttpOnly_")
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+
+		if len(fields) != 7 {
+			return fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNumber, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("line %d: invalid expiry: %s", lineNumber, fields[4])
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+
+		// Without a domain, the cookie is only sent to the exact host
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+
+		// An expiry of 0 is a session cookie
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+
+		scheme := "http"
+
+		if secure {
+			scheme = "https"
+		}
+
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
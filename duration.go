@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	flag "github.com/spf13/pflag"
+	"strconv"
+	"time"
+)
+
+// millisDurationVarP defines a duration flag like flag.DurationVarP, which also accepts a bare number of milliseconds
+func millisDurationVarP(p *time.Duration, name, shorthand string, value time.Duration, usage string) {
+	*p = value
+	flag.VarP((*millisDuration)(p), name, shorthand, usage)
+}
+
+// millisDuration is a flag value that accepts a duration (e.g. 2s) or a bare number of milliseconds,
+// so that --delay and --timeout remain compatible with their original millisecond values
+type millisDuration time.Duration
+
+func (d *millisDuration) Set(s string) error {
+	if ms, err := strconv.ParseUint(s, 10, 63); err == nil {
+		*d = millisDuration(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+
+	duration, err := time.ParseDuration(s)
+
+	if err != nil {
+		return errors.New("expected a duration (e.g. 500ms, 2s) or a number of milliseconds")
+	}
+
+	if duration < 0 {
+		return errors.New("must not be negative")
+	}
+
+	*d = millisDuration(duration)
+	return nil
+}
+
+// String returns "0" instead of "0s" for a zero duration, so that pflag does not print it as a default in the usage
+func (d *millisDuration) String() string {
+	if *d == 0 {
+		return "0"
+	}
+
+	return time.Duration(*d).String()
+}
+
+func (d *millisDuration) Type() string {
+	return "duration"
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envRegexp matches a ${VAR} reference or an escaped $${, a bare $VAR is left untouched (e.g. $top=10 in a query)
+var envRegexp = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} with the value of the environment variable and $${ with a literal ${, and leaves every
+// other dollar sign as is.
+// Unlike os.ExpandEnv, variables that are not set are an error, so that a typo does not silently send an empty value.
+func expandEnv(s string) (string, error) {
+	var missing []string
+
+	expanded := envRegexp.ReplaceAllStringFunc(s, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+
+		name := envRegexp.FindStringSubmatch(reference)[1]
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable not set: %s", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
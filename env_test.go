@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("HTTPING_TEST_HOST", "example.com")
+	t.Setenv("HTTPING_TEST_EMPTY", "")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://${HTTPING_TEST_HOST}/health", "https://example.com/health"},
+		{"${HTTPING_TEST_HOST}${HTTPING_TEST_EMPTY}", "example.com"},
+		{"https://api/x?$top=10", "https://api/x?$top=10"},
+		{"X-Pass: pa$word", "X-Pass: pa$word"},
+		{"$HTTPING_TEST_HOST $$ $ ${} ${1x}", "$HTTPING_TEST_HOST $$ $ ${} ${1x}"},
+		{"https://example.com/$${HTTPING_TEST_UNSET}", "https://example.com/${HTTPING_TEST_UNSET}"},
+		{"$${HTTPING_TEST_HOST}=${HTTPING_TEST_HOST}", "${HTTPING_TEST_HOST}=example.com"},
+		{"$${", "${"},
+	}
+
+	for _, test := range tests {
+		actual, err := expandEnv(test.input)
+
+		if err != nil {
+			t.Errorf("expandEnv(%q) returned %q", test.input, err)
+		} else if actual != test.expected {
+			t.Errorf("expandEnv(%q) returned %q, expected %q", test.input, actual, test.expected)
+		}
+	}
+}
+
+func TestExpandEnvUnsetVariable(t *testing.T) {
+	_, err := expandEnv("https://${HTTPING_TEST_UNSET_A}/${HTTPING_TEST_UNSET_B}")
+
+	if err == nil {
+		t.Fatal("expected an error for unset variables")
+	}
+
+	if expected := "environment variable not set: HTTPING_TEST_UNSET_A, HTTPING_TEST_UNSET_B"; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// statusRange is an inclusive range of status codes
+type statusRange struct {
+	min, max int
+}
+
+// statusRanges is a set of status code ranges, as specified by --expect
+type statusRanges []statusRange
+
+func (r statusRanges) contains(code int) bool {
+	for _, sr := range r {
+		if code >= sr.min && code <= sr.max {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatusRanges parses a comma-separated list of status codes (200), classes (2xx) and ranges (200-299)
+func parseStatusRanges(s string) (statusRanges, error) {
+	var ranges statusRanges
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+
+		var sr statusRange
+		var err error
+
+		if class, found := strings.CutSuffix(part, "xx"); found && len(class) == 1 {
+			sr.min, err = strconv.Atoi(class + "00")
+			sr.max = sr.min + 99
+		} else if from, to, found := strings.Cut(part, "-"); found {
+			sr.min, err = strconv.Atoi(from)
+
+			if err == nil {
+				sr.max, err = strconv.Atoi(to)
+			}
+		} else {
+			sr.min, err = strconv.Atoi(part)
+			sr.max = sr.min
+		}
+
+		if err != nil || sr.min < 100 || sr.max > 599 || sr.min > sr.max {
+			return nil, fmt.Errorf("invalid status code or range: %q", part)
+		}
+
+		ranges = append(ranges, sr)
+	}
+
+	return ranges, nil
+}
+
+// headerExpectation is a response header, as specified by --expect-header.
+// If value is empty and pattern is nil, any value is accepted.
+type headerExpectation struct {
+	name    string
+	value   string
+	pattern *regexp.Regexp
+}
+
+// matches reports whether any of the given header values is the expected one
+func (e headerExpectation) matches(values []string) bool {
+	for _, v := range values {
+		if e.pattern != nil && e.pattern.MatchString(v) || e.pattern == nil && (e.value == "" || v == e.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHeaderExpectation parses "Name: value", where a value between slashes (/regexp/) is a regular expression
+func parseHeaderExpectation(s string) (headerExpectation, error) {
+	name, value, found := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+
+	if !found || name == "" {
+		return headerExpectation{}, fmt.Errorf("%q (expected \"Name: value\")", s)
+	}
+
+	e := headerExpectation{name: name, value: strings.TrimSpace(value)}
+
+	if len(e.value) >= 2 && strings.HasPrefix(e.value, "/") && strings.HasSuffix(e.value, "/") {
+		pattern, err := regexp.Compile(e.value[1 : len(e.value)-1])
+
+		if err != nil {
+			return headerExpectation{}, err
+		}
+
+		e.pattern = pattern
+	}
+
+	return e, nil
+}
+
+// expectationError is returned for responses that do not meet the expectations (--expect, --cert-expiry-threshold,
+// --expect-header, --expect-body)
+type expectationError struct {
+	reason string
+}
+
+func (e *expectationError) Error() string {
+	return e.reason
+}
+
+// checkResponse checks the response and its (possibly truncated) body against the expectations
+func checkResponse(res *http.Response, body []byte) error {
+	if expectedStatuses != nil && !expectedStatuses.contains(res.StatusCode) {
+		return &expectationError{fmt.Sprintf("unexpected status code: %d", res.StatusCode)}
+	}
+
+	// Plain HTTP responses have no certificate to check
+	if certExpiryDays > 0 && res.TLS != nil && len(res.TLS.PeerCertificates) > 0 {
+		days := daysUntil(res.TLS.PeerCertificates[0].NotAfter)
+
+		if days < int(certExpiryDays) {
+			return &expectationError{fmt.Sprintf("certificate expires in %d days", days)}
+		}
+	}
+
+	for _, e := range expectedHeaders {
+		values := res.Header.Values(e.name)
+
+		if len(values) == 0 {
+			return &expectationError{fmt.Sprintf("missing header: %s", e.name)}
+		}
+
+		if !e.matches(values) {
+			return &expectationError{fmt.Sprintf("unexpected header value: %s: %s", e.name, strings.Join(values, ", "))}
+		}
+	}
+
+	if expectedBody != nil && !expectedBody.Match(body) {
+		return &expectationError{"response body does not match --expect-body"}
+	}
+
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - len(b.buf); remaining > 0 {
+		b.buf = append(b.buf, p[:min(len(p), remaining)]...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf
+}
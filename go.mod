@@ -1,6 +1,6 @@
 module httping
 
-go 1.21
+go 1.24
 
 require github.com/montanaflynn/stats v0.7.1
 
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// histogramWidth is the maximum width of a histogram bar, chosen so that every line fits in 80 columns
+const histogramWidth = 40
+
+// printHistogram prints an ASCII histogram of the given latencies, using the given amount of equally sized bins
+func printHistogram(latencies []float64, bins int) {
+	if len(latencies) == 0 || bins <= 0 {
+		return
+	}
+
+	min_ := slices.Min(latencies)
+	max_ := slices.Max(latencies)
+
+	// All latencies are equal, so a single bin is enough
+	if min_ == max_ {
+		bins = 1
+	}
+
+	width := (max_ - min_) / float64(bins)
+	counts := make([]int, bins)
+
+	for _, latency := range latencies {
+		i := 0
+
+		// The maximum would otherwise fall into a bin of its own, so put it into the last bin
+		if width > 0 {
+			i = min(int((latency-min_)/width), bins-1)
+		}
+
+		counts[i]++
+	}
+
+	maxCount := slices.Max(counts)
+
+	for i, c := range counts {
+		from := min_ + float64(i)*width
+		to := from + width
+
+		// Scale the bar, but make sure that non-empty bins are visible
+		bar := c * histogramWidth / maxCount
+
+		if c > 0 && bar == 0 {
+			bar = 1
+		}
+
+		fmt.Fprintf(out, "%9.1fms - %9.1fms | %-*s %d\n", from, to, histogramWidth, strings.Repeat("#", bar), c)
+	}
+}
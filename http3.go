@@ -0,0 +1,121 @@
+//go:build http3
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+)
+
+// newHTTP3Transport returns a transport that sends requests over HTTP/3 (QUIC).
+// QUIC combines the connection setup with the TLS handshake, so only the DNS and TLS phases are reported.
+func newHTTP3Transport(tlsConfig *tls.Config) (http.RoundTripper, error) {
+	newTransport := func() *http3.Transport {
+		return &http3.Transport{
+			TLSClientConfig:    tlsConfig,
+			Dial:               dialQUIC,
+			DisableCompression: disableCompression,
+		}
+	}
+
+	if enableKeepAlive {
+		return newTransport(), nil
+	}
+
+	// Without keep-alive, every request gets its own transport, so that it cannot reuse a connection
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		transport := newTransport()
+		res, err := transport.RoundTrip(req)
+
+		if err != nil {
+			_ = transport.Close()
+			return nil, err
+		}
+
+		res.Body = &closeTransportBody{res.Body, transport}
+		return res, nil
+	}), nil
+}
+
+// dialQUIC resolves the address like the TCP dialer does and reports the QUIC handshake as the TLS handshake
+func dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	if resolved, ok := resolve[strings.ToLower(addr)]; ok {
+		addr = resolved
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	network := "ip"
+
+	if forceIPv4 {
+		network = "ip4"
+	} else if forceIPv6 {
+		network = "ip6"
+	}
+
+	// The request context contains the trace, so the DNS hooks are called by the resolver
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ips) == 0 {
+		return nil, errors.New("no addresses found for " + host)
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+
+	conn, err := quic.DialAddrEarly(ctx, net.JoinHostPort(ips[0].String(), port), tlsCfg, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-conn.HandshakeComplete():
+	case <-ctx.Done():
+		_ = conn.CloseWithError(0, "")
+		return nil, ctx.Err()
+	}
+
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(conn.ConnectionState().TLS, nil)
+	}
+
+	return conn, nil
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeTransportBody closes the transport, and therefore its connection, together with the response body
+type closeTransportBody struct {
+	io.ReadCloser
+	transport io.Closer
+}
+
+func (b *closeTransportBody) Close() error {
+	err := b.ReadCloser.Close()
+	_ = b.transport.Close()
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !http3
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// newHTTP3Transport is only available if httping is built with the http3 build tag (see http3.go)
+func newHTTP3Transport(tlsConfig *tls.Config) (http.RoundTripper, error) {
+	return nil, errors.New("httping was built without HTTP/3 support, build it with -tags http3")
+}
@@ -1,312 +1,2111 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"github.com/montanaflynn/stats"
 	flag "github.com/spf13/pflag"
 	"io"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	targetUrl          string
+	targetUrls         []string
+	targetWeights      []uint
+	targetSchedule     []string
+	weightList         string
 	count              uint
-	delay              uint
-	timeout            uint
+	once               bool
+	delay              time.Duration
+	interval           time.Duration
+	flood              bool
+	timeout            time.Duration
+	dnsTimeout         time.Duration
+	dnsOnly            bool
+	connectOnly        bool
 	enableKeepAlive    bool
+	reconnectEvery     uint
+	maxIdleConns       uint
+	maxIdlePerHost     uint
+	maxConnsPerHost    uint
 	disableCompression bool
 	disableHttp2       bool
 	noNewConnCount     bool
 	userAgent          string
+	method             string
+	headers            []string
+	body               string
+	bodyFile           string
+	contentType        string
+	rawRequestFile     string
+	cacheBust          bool
+	cacheBustParam     string
+	output             string
+	jsonOutput         bool
+	pingStyle          bool
+	noColor            bool
+	warnLatency        time.Duration
+	slowThreshold      time.Duration
+	critLatency        time.Duration
+	followRedirects    bool
+	maxRedirects       uint
+	user               string
+	insecure           bool
+	caCert             string
+	clientCert         string
+	clientKey          string
+	forceIPv4          bool
+	forceIPv6          bool
+	resolves           []string
+	host               string
+	proxy              string
+	duration           time.Duration
+	expect             string
+	failThreshold      float64
+	concurrency        uint
+	rate               float64
+	delayRampSpec      string
+	rateRampSpec       string
+	phaseStats         bool
+	histogram          bool
+	histogramBins      uint
+	timestamp          bool
+	showAddr           bool
+	timestampFormat    string
+	outputFile         string
+	reportInterval     time.Duration
+	maxSamples         uint
+	streamStats        bool
+	percentilesList    string
+	urlFile            string
+	configFile         string
+	bearer             string
+	cookies            bool
+	cookieFile         string
+	quiet              bool
+	tui                bool
+	check              bool
+	warmup             uint
+	successCount       uint
+	failFast           bool
+	retries            uint
+	retryBackoff       time.Duration
+	delayJitter        uint
+	expectBody         string
+	expectBodyLimit    uint
+	expectHeaders      []string
+	maxDownload        uint
+	noDownload         bool
+	useHttp3           bool
+	useH2C             bool
+	unixSocket         string
+	verbose            bool
+	verboseOnce        bool
+	saveBody           string
+	prometheusFile     string
+	pushgateway        string
+	otelEndpoint       string
+	alertWebhook       string
+	bell               bool
+	bellOnRecovery     bool
+	alertAfter         uint
+	alertInterval      time.Duration
+	tlsMin             string
+	tlsMax             string
+	sni                string
+	certExpiry         bool
+	certExpiryDays     uint
 )
 
+// header contains the parsed --header values
+var header = http.Header{}
+
+// resolve maps host:port to the address to connect to instead, as specified by --resolve
+var resolve = map[string]string{}
+
+// percentiles contains the parsed --percentiles value
+var percentiles []float64
+
+// delayRamp and rateRamp contain the parsed --delay-ramp and --rate-ramp values, or nil if the delay or rate is constant
+var delayRamp, rateRamp *ramp
+
+// expectedStatuses contains the parsed --expect value, or nil if any status is accepted
+var expectedStatuses statusRanges
+
+// expectedBody contains the compiled --expect-body value, or nil if any body is accepted
+var expectedBody *regexp.Regexp
+
+// expectedHeaders contains the parsed --expect-header values
+var expectedHeaders []headerExpectation
+
+// requestBody contains the --body or --body-file contents, or nil if no body should be sent
+var requestBody []byte
+
+// tlsVersions maps the versions accepted by --tls-min and --tls-max to their constant
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var methods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodPatch,
+}
+
 func init() {
+	flag.StringVar(&configFile, "config", "", "Path to a TOML or YAML file containing options, which are overridden by the command line")
 	flag.UintVarP(&count, "count", "n", 0, "Number of requests to send")
-	flag.UintVarP(&delay, "delay", "d", 1000, "Minimum delay between requests in milliseconds")
-	flag.UintVarP(&timeout, "timeout", "t", 5000, "Request timeout in milliseconds")
+	flag.BoolVar(&once, "once", false, "Whether to send a single request, like --count 1")
+	flag.UintVar(&successCount, "success-count", 0, "Send requests until this number of requests succeeded, failed requests do not count (--duration is an upper bound)")
+	flag.UintVar(&warmup, "warmup", 0, "Number of requests to send before the actual requests, which are not counted towards the statistics")
+	flag.StringVar(&urlFile, "url-file", "", "Path to a file containing URLs to ping, one per line and optionally followed by a weight (blank lines and lines starting with # are ignored)")
+	flag.StringVar(&weightList, "weight", "", "Comma-separated weights of the URLs in the given order, e.g. 3,1 pings the first URL three times as often as the second (default 1 for every URL)")
+	millisDurationVarP(&delay, "delay", "d", time.Second, "Time between the start of consecutive requests, or longer if a request (including its retries) takes longer (e.g. 500ms, 2s, a bare number is in milliseconds)")
+	millisDurationVarP(&interval, "interval", "", 0, "Start requests exactly every interval instead, skipping the intervals a request overruns (e.g. 1s, a bare number is in milliseconds)")
+	flag.BoolVar(&flood, "flood", false, "Whether to send requests back-to-back without any delay, only printing the number of requests and failures to stderr (like ping -f)")
+	flag.StringVar(&delayRampSpec, "delay-ramp", "", "Multiply --delay by a factor every number of requests until it reaches a limit, given as factor,every,limit (e.g. 0.5,10,50ms)")
+	flag.UintVar(&delayJitter, "jitter", 0, "Randomize every delay by up to this percentage of --delay in either direction (0-100)")
+	millisDurationVarP(&timeout, "timeout", "t", 5*time.Second, "Request timeout (e.g. 500ms, 10s, a bare number is in milliseconds)")
+	millisDurationVarP(&dnsTimeout, "dns-timeout", "", 0, "Timeout of the DNS lookup alone, reported as a DNS timeout (e.g. 500ms, a bare number is in milliseconds, 0 for only --timeout)")
+	flag.BoolVar(&dnsOnly, "dns-only", false, "Whether to only resolve the host of the URL instead of sending requests, to measure the resolver")
+	flag.BoolVar(&connectOnly, "connect-only", false, "Whether to only open and close a TCP connection to the host and port of the URL instead of sending requests (like tcping)")
+	flag.UintVarP(&concurrency, "concurrency", "c", 1, "Number of workers sending requests in parallel, each with its own delay")
+	flag.Float64Var(&rate, "rate", 0, "Number of requests per second to start across all workers, supersedes --delay")
+	flag.StringVar(&rateRampSpec, "rate-ramp", "", "Multiply --rate by a factor every number of requests until it reaches a limit, given as factor,every,limit (e.g. 2,100,500)")
+	flag.UintVar(&retries, "retries", 0, "Number of times to retry a failed request (including unexpected status codes) before counting it as failed")
+	flag.DurationVar(&retryBackoff, "retry-backoff", 100*time.Millisecond, "Delay before the first retry, doubled for every further retry")
+	flag.DurationVar(&duration, "duration", 0, "Stop sending requests after this amount of time (e.g. 30s, 5m)")
 	flag.BoolVar(&enableKeepAlive, "enable-keep-alive", false, "Whether to use keep-alive")
+	flag.UintVar(&reconnectEvery, "reconnect-every", 0, "Close the idle connections every N requests, so that a new connection is used (requires --enable-keep-alive)")
+	flag.UintVar(&maxIdleConns, "max-idle-conns", 0, "Maximum number of idle connections kept open across all hosts (0 for unlimited, requires --enable-keep-alive)")
+	flag.UintVar(&maxIdlePerHost, "max-idle-conns-per-host", 0, "Maximum number of idle connections kept open per host (0 for --concurrency, but at least 2, requires --enable-keep-alive)")
+	flag.UintVar(&maxConnsPerHost, "max-conns-per-host", 0, "Maximum number of connections per host, further requests wait for a connection (0 for unlimited)")
+	flag.UintVar(&maxDownload, "max-download", 0, "Maximum number of bytes of the response body to download, the rest is skipped (0 for unlimited)")
+	flag.BoolVar(&noDownload, "no-download", false, "Whether to close the response body after the first byte instead of downloading it")
 	flag.BoolVar(&disableCompression, "disable-compression", false, "Whether to disable compression")
 	flag.BoolVar(&disableHttp2, "disable-h2", false, "Whether to disable HTTP/2")
+	flag.BoolVar(&useH2C, "h2c", false, "Whether to use cleartext HTTP/2 with prior knowledge for http:// URLs (HTTPS URLs then require HTTP/2 as well)")
+	flag.BoolVar(&useHttp3, "http3", false, "Whether to use HTTP/3 (QUIC) instead of TCP, requires a build with the http3 build tag")
 	flag.BoolVar(&noNewConnCount, "no-new-conn-count", false, "Whether to not count requests that did not reuse a connection towards the final statistics")
-	flag.StringVar(&userAgent, "user-agent", "httping (https://github.com/GitRowin/httping)", "Change the User-Agent header")
+	flag.BoolVar(&histogram, "histogram", false, "Whether to print a histogram of the total latency in the final statistics")
+	flag.UintVar(&histogramBins, "histogram-bins", 10, "Number of bins to use for the histogram")
+	flag.UintVar(&maxSamples, "max-samples", 100000, "Maximum number of latency samples to keep for the final statistics, older samples are dropped (0 for unlimited)")
+	flag.StringVar(&percentilesList, "percentiles", "99,95,90,75,50", "Comma-separated list of the percentiles to print in the final statistics (e.g. 50,90,99,99.9), empty to print none")
+	flag.BoolVar(&streamStats, "stream-stats", false, "Whether to estimate the percentiles using constant memory instead of storing every sample")
+	flag.DurationVar(&reportInterval, "report-interval", 0, "Print statistics over the last interval every interval (e.g. 10s)")
+	flag.BoolVar(&phaseStats, "phase-stats", false, "Whether to print statistics for every phase (dns, conn, tls, send, ttfb, dl) in the final statistics")
+	flag.StringVar(&userAgent, "user-agent", "httping (https://github.com/GitRowin/httping)", "Change the User-Agent header (empty to not send the header at all)")
+	flag.StringVar(&method, "method", http.MethodGet, "HTTP method to use ("+strings.Join(methods, ", ")+")")
+	flag.StringArrayVarP(&headers, "header", "H", nil, "Add a request header (e.g. \"Accept: application/json\"), can be repeated")
+	flag.StringVar(&body, "body", "", "Request body to send")
+	flag.StringVar(&bodyFile, "body-file", "", "Path to a file containing the request body to send")
+	flag.BoolVar(&cacheBust, "cache-bust", false, "Whether to append a unique query parameter to every request, so that caches in between do not answer instead of the origin")
+	flag.StringVar(&cacheBustParam, "cache-bust-param", "_", "Name of the query parameter appended by --cache-bust")
+	flag.StringVar(&rawRequestFile, "raw-request", "", "Path to a file containing a raw HTTP request to replay (request line, headers and body), which is sent to the scheme and host of the URL")
+	flag.StringVar(&contentType, "content-type", "", "Content-Type of the request body, inferred from the --body-file extension if not given (e.g. application/json for .json)")
+	flag.StringVar(&saveBody, "save-body", "", "Path to a directory to save every response body to (named after the time, request number and status code)")
+	flag.StringVarP(&output, "output", "o", outputText, "Output format ("+strings.Join(outputs, ", ")+")")
+	flag.BoolVar(&jsonOutput, "json", false, "Shorthand for --output=json")
+	flag.BoolVar(&pingStyle, "ping-style", false, "Shorthand for --output=ping, which prints lines like ping (e.g. seq=3 status=200 time=45.2 ms)")
+	flag.StringVar(&outputFile, "output-file", "", "Path to a file to write the output to in addition to stdout (without colors)")
+	flag.BoolVar(&timestamp, "timestamp", false, "Whether to prefix every line with the time the request was sent")
+	flag.BoolVar(&showAddr, "show-addr", false, "Whether to print the remote and local address of the connection, e.g. to see which server answered")
+	flag.StringVar(&timestampFormat, "timestamp-format", "15:04:05.000", "Format of the timestamp, either a name (e.g. RFC3339) or a Go layout")
+	flag.StringVar(&prometheusFile, "prometheus", "", "Path to a file to write the statistics to in the Prometheus text format (e.g. for the node exporter textfile collector)")
+	flag.StringVar(&pushgateway, "pushgateway", "", "URL of a Prometheus Pushgateway to push the statistics to")
+	flag.StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint to export every request to as an OpenTelemetry trace (e.g. http://localhost:4318)")
+	flag.StringVar(&alertWebhook, "alert-webhook", "", "URL to POST a JSON alert to when requests fail (e.g. a Slack or Discord webhook)")
+	flag.UintVar(&alertAfter, "alert-after", 1, "Number of consecutive failed requests to a target before an alert is sent")
+	flag.DurationVar(&alertInterval, "alert-interval", 5*time.Minute, "Minimum time between alerts for the same target")
+	flag.BoolVarP(&quiet, "quiet", "q", false, "Whether to only print the final statistics")
+	flag.BoolVar(&tui, "tui", false, "Whether to show a live dashboard instead of a line per request (only if stdout is a terminal)")
+	flag.BoolVarP(&verbose, "verbose", "v", false, "Whether to print the response status line and headers of every request")
+	flag.BoolVar(&verboseOnce, "verbose-once", false, "Whether to print the response status line and headers of the first request only")
+	flag.BoolVar(&check, "check", false, "Whether to print nothing and only report success through the exit code (sends 1 request and expects 2xx unless specified otherwise)")
+	flag.BoolVar(&bell, "bell", false, "Whether to ring the terminal bell on every failed request")
+	flag.BoolVar(&bellOnRecovery, "bell-on-recovery", false, "Whether to ring the terminal bell when a target succeeds again after failing")
+	millisDurationVarP(&slowThreshold, "slow-threshold", "", 0, "Mark successful requests that take longer than this as slow, without failing them (e.g. 500ms, a bare number is in milliseconds)")
+	millisDurationVarP(&warnLatency, "warn-latency", "", 0, "Print the total latency in yellow if it exceeds this duration (e.g. 200ms, a bare number is in milliseconds)")
+	millisDurationVarP(&critLatency, "crit-latency", "", 0, "Print the total latency in red if it exceeds this duration (e.g. 1s, a bare number is in milliseconds)")
+	flag.BoolVar(&noColor, "no-color", false, "Whether to disable colored output (automatically disabled if stdout is not a terminal)")
+	flag.BoolVar(&followRedirects, "follow-redirects", false, "Whether to follow redirects")
+	flag.UintVar(&maxRedirects, "max-redirects", 10, "Maximum number of redirects to follow")
+	flag.StringVarP(&user, "user", "u", "", "Basic authentication credentials (user:password)")
+	flag.StringVar(&bearer, "bearer", "", "Bearer token to send in the Authorization header")
+	flag.BoolVar(&cookies, "cookies", false, "Whether to keep the cookies set by responses and send them with subsequent requests")
+	flag.StringVar(&cookieFile, "cookie-file", "", "Path to a Netscape cookie file (e.g. from curl -c) to load the initial cookies from, implies --cookies")
+	flag.BoolVarP(&insecure, "insecure", "k", false, "Whether to skip TLS certificate verification")
+	flag.StringVar(&tlsMin, "tls-min", "", "Minimum TLS version to use (1.0, 1.1, 1.2 or 1.3)")
+	flag.StringVar(&tlsMax, "tls-max", "", "Maximum TLS version to use (1.0, 1.1, 1.2 or 1.3)")
+	flag.BoolVar(&certExpiry, "cert-expiry", false, "Whether to print the number of days until the server certificate expires")
+	flag.UintVar(&certExpiryDays, "cert-expiry-threshold", 0, "Fail requests if the server certificate expires within this number of days (0 to disable)")
+	flag.StringVar(&caCert, "cacert", "", "Path to a PEM file containing CA certificates to trust instead of the system ones")
+	flag.StringVar(&clientCert, "cert", "", "Path to a PEM file containing the client certificate (requires --key)")
+	flag.StringVar(&clientKey, "key", "", "Path to a PEM file containing the client private key (requires --cert)")
+	flag.BoolVarP(&forceIPv4, "ipv4", "4", false, "Whether to only use IPv4")
+	flag.BoolVarP(&forceIPv6, "ipv6", "6", false, "Whether to only use IPv6")
+	flag.StringArrayVar(&resolves, "resolve", nil, "Connect to a specific address for a host and port (host:port:addr), can be repeated")
+	flag.StringVar(&unixSocket, "unix-socket", "", "Connect to this Unix domain socket instead of the URL host (the Host header still uses the URL host)")
+	flag.StringVar(&host, "host", "", "Override the Host header (TLS SNI still uses the URL host, see --sni)")
+	flag.StringVar(&sni, "sni", "", "Override the TLS server name (SNI), which the certificate is also verified against")
+	flag.StringVar(&expect, "expect", "", "Expected status codes (e.g. 200, 2xx, 200-299 or a comma-separated list), other statuses count as failed")
+	flag.StringVar(&expectBody, "expect-body", "", "Regular expression the response body must match, other responses count as failed")
+	flag.UintVar(&expectBodyLimit, "expect-body-limit", 1<<20, "Maximum number of bytes of the response body to match against --expect-body")
+	flag.StringArrayVar(&expectHeaders, "expect-header", nil, "Response header the response must contain (e.g. \"Cache-Control: no-cache\", a value between slashes is a regular expression, an empty value accepts any), can be repeated")
+	flag.BoolVar(&failFast, "fail-fast", false, "Whether to stop after the first failed request (including unexpected status codes) and exit with code 1")
+	flag.Float64Var(&failThreshold, "fail-threshold", 0, "Exit with code 1 if the percentage of failed requests exceeds this value")
+	flag.StringVar(&proxy, "proxy", "", "Proxy URL (http://, https:// or socks5://), defaults to the HTTP_PROXY and HTTPS_PROXY environment variables")
+}
+
+type TLSNextProtoMap = map[string]func(authority string, c *tls.Conn) http.RoundTripper
+
+// Statistics stores all request statistics.
+// All pointer fields are optional. If a field is nil or an empty string, "N/A" is printed.
+type Statistics struct {
+	Target       string
+	Seq          uint64
+	Start        time.Time
+	DNS          *time.Duration
+	Connect      *time.Duration
+	TLSHandshake *time.Duration
+	Send         *time.Duration
+	TTFB         *time.Duration
+	Server       *time.Duration
+	Download     *time.Duration
+	Total        *time.Duration
+	Bytes        *int64
+	Truncated    bool
+	Reused       *bool
+	ConnWait     *time.Duration
+	RemoteAddr   string
+	LocalAddr    string
+	Proto        string
+	TLSVersion   string
+	CipherSuite  string
+	CertSubject  string
+	CertExpiry   *time.Time
+	Status       string
+	StatusCode   int
+	Redirects    int
+	Retries      int
+	Warmup       bool
+
+	// Delay and Rate are the delay and rate in effect for the request, only set with --delay-ramp and --rate-ramp
+	Delay *time.Duration
+	Rate  *float64
+
+	// Number of --interval intervals skipped because the request overran them
+	SkippedIntervals int64
+
+	// Header contains the response headers, which are only printed with --verbose or --verbose-once
+	Header http.Header
+
+	// How the response body was framed: the transfer encodings (e.g. chunked) or the declared length, which is -1 if unknown
+	TransferEncoding []string
+	ContentLength    int64
+
+	// Informational contains the 1xx responses received before the final response (e.g. 103 Early Hints)
+	Informational []informationalResponse
+
+	// Start of every phase, which is zero if the phase did not happen (the TTFB phase starts at Start)
+	DNSStart          time.Time
+	ConnectStart      time.Time
+	TLSHandshakeStart time.Time
+	SendStart         time.Time
+	DownloadStart     time.Time
+
+	// When a connection was requested from the pool, for ConnWait
+	GetConnStart time.Time
+}
+
+// informationalResponse is a 1xx response, received after Time since the start of the request
+type informationalResponse struct {
+	StatusCode int
+	Header     textproto.MIMEHeader
+	Time       time.Duration
+}
+
+// earlyHints returns the time until the first 103 Early Hints response, or nil if there was none
+func (s *Statistics) earlyHints() *time.Duration {
+	for _, r := range s.Informational {
+		if r.StatusCode == http.StatusEarlyHints {
+			return &r.Time
+		}
+	}
+	return nil
+}
+
+// timestampFormats maps the names accepted by --timestamp-format to their layout
+var timestampFormats = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"DateTime":    time.DateTime,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// phaseNames contains the names of the phases returned by Statistics.phases
+var phaseNames = []string{"dns", "conn", "tls", "send", "ttfb", "dl"}
+
+// phases returns the duration of every phase, in the same order as phaseNames
+func (s *Statistics) phases() []*time.Duration {
+	return []*time.Duration{s.DNS, s.Connect, s.TLSHandshake, s.Send, s.TTFB, s.Download}
+}
+
+// phaseStarts returns the start of every phase, in the same order as phaseNames
+func (s *Statistics) phaseStarts() []time.Time {
+	return []time.Time{s.DNSStart, s.ConnectStart, s.TLSHandshakeStart, s.SendStart, s.Start, s.DownloadStart}
+}
+
+// setTLSState stores the negotiated TLS version and cipher suite
+func (s *Statistics) setTLSState(state tls.ConnectionState) {
+	s.TLSVersion = tls.VersionName(state.Version)
+	s.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+
+	if len(state.PeerCertificates) > 0 {
+		s.CertSubject = state.PeerCertificates[0].Subject.String()
+		s.CertExpiry = &state.PeerCertificates[0].NotAfter
+	}
+}
+
+// certExpiryDays returns the number of whole days until the server certificate expires, or nil without a certificate
+func (s *Statistics) certExpiryDays() *int {
+	if s.CertExpiry == nil {
+		return nil
+	}
+	days := daysUntil(*s.CertExpiry)
+	return &days
+}
+
+// daysUntil returns the number of whole days until the given time, which is negative if it has passed
+func daysUntil(t time.Time) int {
+	return int(time.Until(t).Hours() / 24)
+}
+
+// slow returns whether the request succeeded (without an error message), but took longer than --slow-threshold
+func (s *Statistics) slow(errMsg string) bool {
+	return slowThreshold > 0 && errMsg == "" && *s.Total > slowThreshold
+}
+
+// throughput returns the download throughput in MB/s, or nil if nothing was downloaded
+func (s *Statistics) throughput() *float64 {
+	if s.Bytes == nil || *s.Bytes == 0 || s.Download == nil || *s.Download <= 0 {
+		return nil
+	}
+	mbps := float64(*s.Bytes) / 1e6 / s.Download.Seconds()
+	return &mbps
+}
+
+// result is the outcome of a single request, as sent from a worker to the main goroutine
+type result struct {
+	statistics *Statistics
+	err        error
 }
 
-type TLSNextProtoMap = map[string]func(authority string, c *tls.Conn) http.RoundTripper
+func main() {
+	flag.CommandLine.SortFlags = false
+	flag.Parse()
+
+	targetUrls = flag.Args()
+
+	if configFile != "" {
+		err := applyConfig(flag.CommandLine, configFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config file %s: %s\n", configFile, err)
+			os.Exit(-1)
+		}
+	}
+
+	// The URLs of the URL file are expanded and validated while reading it
+	for i, targetUrl := range targetUrls {
+		expanded, err := expandEnv(targetUrl)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid URL %s: %s\n", targetUrl, err)
+			os.Exit(-1)
+		}
+
+		targetUrl = withDefaultScheme(expanded)
+
+		if err := validateUrl(targetUrl); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid URL: %s\n", err)
+			os.Exit(-1)
+		}
+
+		targetUrls[i] = targetUrl
+	}
+
+	// URLs given on the command line or in the config file have a weight of 1, unless --weight is given
+	weights := make([]uint, len(targetUrls))
+
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	if urlFile != "" {
+		urls, fileWeights, err := readUrlFile(urlFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid URL file %s:\n%s\n", urlFile, err)
+			os.Exit(-1)
+		}
+
+		targetUrls = append(targetUrls, urls...)
+		weights = append(weights, fileWeights...)
+	}
+
+	if len(targetUrls) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: httping [options] <url>...")
+		flag.PrintDefaults()
+		os.Exit(-1)
+	}
+
+	if weightList != "" {
+		var err error
+		weights, err = parseWeights(weightList)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --weight: %s\n", err)
+			os.Exit(-1)
+		}
+
+		if len(weights) != len(targetUrls) {
+			fmt.Fprintf(os.Stderr, "--weight must contain one weight for each of the %d URLs\n", len(targetUrls))
+			os.Exit(-1)
+		}
+	}
+
+	targetWeights = weights
+
+	// The raw request replaces the method, path, headers and body, so that it is replayed as it is
+	var rawRequest *http.Request
+	var rawBody []byte
+
+	if rawRequestFile != "" {
+		if flag.CommandLine.Changed("method") || flag.CommandLine.Changed("body") || flag.CommandLine.Changed("body-file") {
+			fmt.Fprintln(os.Stderr, "--raw-request cannot be used with --method, --body or --body-file")
+			os.Exit(-1)
+		}
+
+		var err error
+		rawRequest, rawBody, err = readRawRequest(rawRequestFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid raw request %s: %s\n", rawRequestFile, err)
+			os.Exit(-1)
+		}
+
+		method = rawRequest.Method
+
+		// The Host header of the raw request is kept, unless it is overridden with --host
+		if host == "" {
+			host = rawRequest.Host
+		}
+	}
+
+	if rawRequest != nil {
+		for i, targetUrl := range targetUrls {
+			targetUrls[i] = withRequestURI(targetUrl, rawRequest.URL)
+		}
+	}
+
+	targetSchedule = weightedSchedule(targetUrls, targetWeights)
+
+	if once {
+		if flag.CommandLine.Changed("count") || successCount > 0 {
+			fmt.Fprintln(os.Stderr, "--once cannot be combined with --count or --success-count")
+			os.Exit(-1)
+		}
+
+		count = 1
+	}
+
+	if check {
+		quiet = true
+
+		if !flag.CommandLine.Changed("count") && successCount == 0 {
+			count = 1
+		}
+
+		if expect == "" {
+			expect = "2xx"
+		}
+	}
+
+	if count > 0 && successCount > 0 {
+		fmt.Fprintln(os.Stderr, "--count and --success-count are mutually exclusive")
+		os.Exit(-1)
+	}
+
+	if concurrency == 0 {
+		fmt.Fprintln(os.Stderr, "--concurrency must be at least 1")
+		os.Exit(-1)
+	}
+
+	if delayJitter > 100 {
+		fmt.Fprintln(os.Stderr, "--jitter must be between 0 and 100")
+		os.Exit(-1)
+	}
+
+	if percentilesList != "" {
+		var err error
+		percentiles, err = parsePercentiles(percentilesList)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid percentiles: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if warnLatency > 0 && critLatency > 0 && warnLatency > critLatency {
+		fmt.Fprintln(os.Stderr, "--warn-latency must not be greater than --crit-latency")
+		os.Exit(-1)
+	}
+
+	if reconnectEvery > 0 && !enableKeepAlive {
+		fmt.Fprintln(os.Stderr, "--reconnect-every requires --enable-keep-alive, as every request uses a new connection otherwise")
+		os.Exit(-1)
+	}
+
+	if (maxIdleConns > 0 || maxIdlePerHost > 0) && !enableKeepAlive {
+		fmt.Fprintln(os.Stderr, "--max-idle-conns and --max-idle-conns-per-host require --enable-keep-alive, as no connection is kept idle otherwise")
+		os.Exit(-1)
+	}
+
+	// Go keeps only 2 idle connections per host by default, so that most connections of more workers would be closed
+	// after every request instead of being reused
+	if maxIdlePerHost == 0 {
+		maxIdlePerHost = max(concurrency, http.DefaultMaxIdleConnsPerHost)
+	}
+
+	if alertAfter == 0 {
+		fmt.Fprintln(os.Stderr, "--alert-after must be at least 1")
+		os.Exit(-1)
+	}
+
+	if saveBody != "" {
+		err := os.MkdirAll(saveBody, 0o755)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create body directory: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if (verbose || verboseOnce) && (output == outputCSV || output == outputInflux) {
+		fmt.Fprintln(os.Stderr, "--verbose and --verbose-once cannot be used with CSV or InfluxDB output")
+		os.Exit(-1)
+	}
+
+	if streamStats && histogram {
+		fmt.Fprintln(os.Stderr, "--histogram requires every sample, so it cannot be used with --stream-stats")
+		os.Exit(-1)
+	}
+
+	if rate < 0 {
+		fmt.Fprintln(os.Stderr, "--rate must not be negative")
+		os.Exit(-1)
+	}
+
+	if interval < 0 {
+		fmt.Fprintln(os.Stderr, "--interval must not be negative")
+		os.Exit(-1)
+	}
+
+	if interval > 0 && (rate > 0 || delayJitter > 0 || flag.CommandLine.Changed("delay")) {
+		fmt.Fprintln(os.Stderr, "--interval cannot be used with --delay, --rate or --jitter")
+		os.Exit(-1)
+	}
+
+	if flood {
+		if interval > 0 || rate > 0 || flag.CommandLine.Changed("delay") {
+			fmt.Fprintln(os.Stderr, "--flood cannot be used with --delay, --interval or --rate")
+			os.Exit(-1)
+		}
+
+		delay = 0
+		fmt.Fprintf(os.Stderr, "Warning: flooding with %d worker(s), requests are sent as fast as the server responds\n", concurrency)
+	}
+
+	if delayRampSpec != "" {
+		if delay == 0 || interval > 0 || rate > 0 {
+			fmt.Fprintln(os.Stderr, "--delay-ramp requires a --delay greater than 0, and cannot be used with --interval, --rate or --flood")
+			os.Exit(-1)
+		}
+
+		var err error
+		delayRamp, err = parseRamp(delayRampSpec, float64(delay), parseDelayLimit)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --delay-ramp: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if rateRampSpec != "" {
+		if rate == 0 {
+			fmt.Fprintln(os.Stderr, "--rate-ramp requires --rate")
+			os.Exit(-1)
+		}
+
+		var err error
+		rateRamp, err = parseRamp(rateRampSpec, rate, parseRateLimit)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --rate-ramp: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if jsonOutput {
+		output = outputJSON
+	}
+
+	if pingStyle {
+		output = outputPing
+	}
+
+	if output == outputNDJSON {
+		output = outputJSON
+	}
+
+	if !slices.Contains(outputs, output) {
+		fmt.Fprintf(os.Stderr, "Invalid output format: %s\n", output)
+		os.Exit(-1)
+	}
+
+	var file *os.File
+
+	if outputFile != "" {
+		var err error
+		file, err = os.Create(outputFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %s\n", err)
+			os.Exit(-1)
+		}
+
+		out = io.MultiWriter(os.Stdout, &stripColorWriter{file})
+	}
+
+	if layout, ok := timestampFormats[timestampFormat]; ok {
+		timestampFormat = layout
+	}
+
+	if !isTerminal(os.Stdout) {
+		noColor = true
+
+		// Fall back to printing a line per request, e.g. if the output is piped
+		tui = false
+	}
+
+	if tui && (output != outputText || quiet || outputFile != "" || verbose || verboseOnce) {
+		fmt.Fprintln(os.Stderr, "--tui cannot be used with other output formats, --quiet, --output-file or --verbose")
+		os.Exit(-1)
+	}
+
+	method = strings.ToUpper(method)
+
+	if !slices.Contains(methods, method) {
+		fmt.Fprintf(os.Stderr, "Invalid method: %s\n", method)
+		os.Exit(-1)
+	}
+
+	for _, h := range headers {
+		// Split on the first colon only, so that values containing colons are preserved
+		key, value, found := strings.Cut(h, ":")
+		key = strings.TrimSpace(key)
+
+		if !found || key == "" {
+			fmt.Fprintf(os.Stderr, "Invalid header: %q (expected \"Name: value\")\n", h)
+			os.Exit(-1)
+		}
+
+		value, err := expandEnv(strings.TrimSpace(value))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid header %s: %s\n", key, err)
+			os.Exit(-1)
+		}
+
+		header.Add(key, value)
+	}
+
+	// Headers given with --header take precedence over the ones of the raw request
+	if rawRequest != nil {
+		for key, values := range rawRequest.Header {
+			if _, ok := header[key]; !ok {
+				header[key] = values
+			}
+		}
+	}
+
+	if user != "" && bearer != "" {
+		fmt.Fprintln(os.Stderr, "--user and --bearer are mutually exclusive")
+		os.Exit(-1)
+	}
+
+	if bearer != "" {
+		var err error
+		bearer, err = expandEnv(bearer)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid bearer: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if expect != "" {
+		var err error
+		expectedStatuses, err = parseStatusRanges(expect)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid expect: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	if expectBody != "" && noDownload {
+		fmt.Fprintln(os.Stderr, "--expect-body requires the response body, so it cannot be used with --no-download")
+		os.Exit(-1)
+	}
+
+	// HEAD responses have no body, so every request would fail
+	if expectBody != "" && method == http.MethodHead {
+		fmt.Fprintln(os.Stderr, "--expect-body requires the response body, so it cannot be used with --method HEAD")
+		os.Exit(-1)
+	}
+
+	if expectBody != "" {
+		var err error
+		expectedBody, err = regexp.Compile(expectBody)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid expect body: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	for _, h := range expectHeaders {
+		e, err := parseHeaderExpectation(h)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid expect header: %s\n", err)
+			os.Exit(-1)
+		}
+
+		expectedHeaders = append(expectedHeaders, e)
+	}
+
+	if flag.CommandLine.Changed("body") && flag.CommandLine.Changed("body-file") {
+		fmt.Fprintln(os.Stderr, "--body and --body-file are mutually exclusive")
+		os.Exit(-1)
+	}
+
+	if flag.CommandLine.Changed("body") {
+		requestBody = []byte(body)
+	} else if bodyFile != "" {
+		// Read the file once, since the request is rebuilt on every iteration
+		data, err := os.ReadFile(bodyFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read body file: %s\n", err)
+			os.Exit(-1)
+		}
+
+		requestBody = data
+	} else if rawRequest != nil {
+		requestBody = rawBody
+	}
+
+	if cacheBust && cacheBustParam == "" {
+		fmt.Fprintln(os.Stderr, "--cache-bust-param must not be empty")
+		os.Exit(-1)
+	}
+
+	// A Content-Type given with --header takes precedence
+	if header.Get("Content-Type") == "" {
+		if contentType == "" && bodyFile != "" && requestBody != nil {
+			contentType = mime.TypeByExtension(filepath.Ext(bodyFile))
+		}
+
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+		ServerName:         sni,
+	}
+
+	if insecure {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled")
+	}
+
+	if tlsMin != "" {
+		version, ok := tlsVersions[tlsMin]
+
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Invalid TLS version: %s\n", tlsMin)
+			os.Exit(-1)
+		}
+
+		tlsConfig.MinVersion = version
+	}
+
+	if tlsMax != "" {
+		version, ok := tlsVersions[tlsMax]
+
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Invalid TLS version: %s\n", tlsMax)
+			os.Exit(-1)
+		}
+
+		tlsConfig.MaxVersion = version
+	}
+
+	if tlsConfig.MinVersion != 0 && tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		fmt.Fprintln(os.Stderr, "--tls-min must not be greater than --tls-max")
+		os.Exit(-1)
+	}
+
+	if caCert != "" {
+		data, err := os.ReadFile(caCert)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read CA certificate file: %s\n", err)
+			os.Exit(-1)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			fmt.Fprintf(os.Stderr, "No valid certificates found in %s\n", caCert)
+			os.Exit(-1)
+		}
+	}
+
+	if (clientCert == "") != (clientKey == "") {
+		fmt.Fprintln(os.Stderr, "--cert and --key must be used together")
+		os.Exit(-1)
+	}
+
+	if clientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(clientCert, clientKey)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load client certificate: %s\n", err)
+			os.Exit(-1)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if forceIPv4 && forceIPv6 {
+		fmt.Fprintln(os.Stderr, "--ipv4 and --ipv6 are mutually exclusive")
+		os.Exit(-1)
+	}
+
+	// Without requests, no connection is reused
+	if (dnsOnly || connectOnly) && noNewConnCount {
+		fmt.Fprintln(os.Stderr, "--dns-only and --connect-only cannot be used with --no-new-conn-count")
+		os.Exit(-1)
+	}
+
+	if connectOnly && (dnsOnly || useHttp3 || proxy != "") {
+		fmt.Fprintln(os.Stderr, "--connect-only cannot be used with --dns-only, --http3 or --proxy")
+		os.Exit(-1)
+	}
+
+	for _, r := range resolves {
+		host, rest, _ := strings.Cut(r, ":")
+		port, addr, found := strings.Cut(rest, ":")
+
+		// Allow IPv6 addresses to be enclosed in brackets, like in URLs
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+
+		if !found || host == "" || port == "" || net.ParseIP(addr) == nil {
+			fmt.Fprintf(os.Stderr, "Invalid resolve: %q (expected \"host:port:addr\")\n", r)
+			os.Exit(-1)
+		}
+
+		resolve[strings.ToLower(net.JoinHostPort(host, port))] = net.JoinHostPort(addr, port)
+	}
+
+	dialer := &net.Dialer{}
+
+	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// Every connection goes to the socket, regardless of the URL host
+		if unixSocket != "" {
+			return dialer.DialContext(ctx, "unix", unixSocket)
+		}
+
+		// Only the dialed address is changed, the Host header and SNI still use the original host
+		if resolved, ok := resolve[strings.ToLower(addr)]; ok {
+			addr = resolved
+		}
+
+		// Restrict the address family, so that only A or AAAA records are used
+		if forceIPv4 {
+			network = "tcp4"
+		} else if forceIPv6 {
+			network = "tcp6"
+		}
+
+		if dnsTimeout > 0 {
+			return dialWithDNSTimeout(ctx, dialer, network, addr)
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	proxyFunc := http.ProxyFromEnvironment
+
+	if unixSocket != "" && (proxy != "" || useHttp3) {
+		fmt.Fprintln(os.Stderr, "--unix-socket cannot be used with --proxy or --http3")
+		os.Exit(-1)
+	}
+
+	// Requests to the socket must not be sent to a proxy from the environment
+	if unixSocket != "" {
+		proxyFunc = nil
+	}
+
+	if proxy != "" {
+		proxyUrl, err := url.Parse(proxy)
+
+		if err != nil || !slices.Contains([]string{"http", "https", "socks5"}, proxyUrl.Scheme) {
+			fmt.Fprintf(os.Stderr, "Invalid proxy: %s\n", proxy)
+			os.Exit(-1)
+		}
+
+		// The standard library supports SOCKS5 proxies as well
+		proxyFunc = http.ProxyURL(proxyUrl)
+	}
+
+	var tlsNextProto TLSNextProtoMap
+
+	if disableHttp2 {
+		// Setting TLSNextProto to an empty map disables HTTP/2
+		tlsNextProto = TLSNextProtoMap{}
+	}
+
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse // Do not follow redirects
+	}
+
+	if followRedirects {
+		checkRedirect = func(req *http.Request, via []*http.Request) error {
+			// via contains all previous requests, so it has one entry for the original request
+			if len(via) > int(maxRedirects) {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		}
+	}
+
+	httpTransport := &http.Transport{
+		Proxy:               proxyFunc,
+		DialContext:         dialContext,
+		DisableKeepAlives:   !enableKeepAlive,
+		MaxIdleConns:        int(maxIdleConns),
+		MaxIdleConnsPerHost: int(maxIdlePerHost),
+		MaxConnsPerHost:     int(maxConnsPerHost),
+		DisableCompression:  disableCompression,
+		TLSClientConfig:     tlsConfig,
+		TLSNextProto:        tlsNextProto,
+		// A custom DialContext or TLSClientConfig disables HTTP/2 unless it is forced
+		ForceAttemptHTTP2: true,
+	}
+
+	if useH2C {
+		if disableHttp2 || useHttp3 {
+			fmt.Fprintln(os.Stderr, "--h2c cannot be used with --disable-h2 or --http3")
+			os.Exit(-1)
+		}
+
+		// Without HTTP1, plain TCP connections speak HTTP/2 right away instead of upgrading
+		httpTransport.Protocols = &http.Protocols{}
+		httpTransport.Protocols.SetUnencryptedHTTP2(true)
+		httpTransport.Protocols.SetHTTP2(true)
+	}
+
+	var transport http.RoundTripper = httpTransport
+
+	if useHttp3 {
+		if proxy != "" {
+			fmt.Fprintln(os.Stderr, "--proxy cannot be used with --http3")
+			os.Exit(-1)
+		}
+
+		var err error
+		transport, err = newHTTP3Transport(tlsConfig)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to enable HTTP/3: %s\n", err)
+			os.Exit(-1)
+		}
+	}
+
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+		Timeout:       timeout,
+	}
+
+	// The jar is shared by all workers, so that a session established by one request is used by all following ones
+	if cookies || cookieFile != "" {
+		jar, err := newCookieJar(cookieFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load cookie file: %s\n", err)
+			os.Exit(-1)
+		}
+
+		client.Jar = jar
+	}
+
+	// Cancels the requests that are in flight, which is only done by --fail-fast
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Stops sending new requests, but lets the requests that are in flight finish
+	interruptCtx, interrupt := context.WithCancel(ctx)
+
+	var dash *dashboard
+
+	if tui {
+		dash = newDashboard(os.Stdout)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+
+	// Asynchronously wait for an interruption. A second one quits without waiting for the requests in flight.
+	go func() {
+		<-c
+		fmt.Fprintln(os.Stderr, "Waiting for the requests in flight to finish, interrupt again to quit immediately")
+		interrupt()
+		<-c
+
+		if dash != nil {
+			dash.Close()
+		}
+
+		os.Exit(1)
+	}()
+
+	// Amount of requests that failed because the response did not meet the expectations
+	var unexpected uint
+
+	// Whether a request failed with --fail-fast enabled
+	var failedFast bool
+
+	// Whether the response headers have been printed, for --verbose-once
+	var printedHeaders bool
+
+	// Number of requests and failures so far, for --flood
+	var progress *floodProgress
+
+	if flood && (output == outputText || output == outputPing) && !quiet {
+		progress = newFloodProgress()
+	}
+
+	// Targets whose last request failed, for --bell-on-recovery
+	failing := map[string]bool{}
+
+	// Statistics of every target, the same URL given twice shares its statistics
+	summaries := map[string]*summary{}
+
+	for _, targetUrl := range targetUrls {
+		summaries[targetUrl] = newSummary()
+	}
+
+	if output == outputCSV && !quiet {
+		printCSVHeader()
+	}
+
+	// Machine-readable output only contains the requests, and the dashboard has a header of its own
+	if (output == outputText || output == outputPing) && !quiet && dash == nil {
+		printBanner()
+	}
+
+	// Cancelled once the program is interrupted or the requested duration has elapsed.
+	// Requests use ctx instead, so that the requests in flight can finish.
+	stopCtx := interruptCtx
+
+	if duration > 0 {
+		var stop context.CancelFunc
+		stopCtx, stop = context.WithTimeout(interruptCtx, duration)
+		defer stop()
+	}
+
+	var limiter *rateLimiter
+
+	if rate > 0 {
+		limiter = newRateLimiter(rate)
+	}
+
+	results := make(chan result)
+
+	// Amount of requests started by all workers combined
+	var started atomic.Uint64
+
+	// Amount of successful requests (excluding warmup requests) of all workers combined, for --success-count
+	var succeeded atomic.Uint64
+
+	var wg sync.WaitGroup
+
+	for i := uint(0); i < concurrency; i++ {
+		wg.Add(1)
+
+		// With --interval, the workers are spread evenly over the interval instead of starting in a burst
+		offset := interval * time.Duration(i) / time.Duration(concurrency)
+
+		go func() {
+			defer wg.Done()
+			worker(client, ctx, stopCtx, limiter, results, &started, &succeeded, offset)
+		}()
+	}
+
+	// Close the results channel once all workers have stopped
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var exporter *otelExporter
+
+	if otelEndpoint != "" {
+		exporter = newOtelExporter(otelEndpoint)
+	}
+
+	var alerts *alerter
+
+	if alertWebhook != "" {
+		alerts = newAlerter(alertWebhook)
+	}
+
+	// Receives SIGHUP and SIGUSR1, which print the current statistics to stderr
+	summaryRequests := make(chan os.Signal, 1)
+
+	if len(summarySignals) > 0 {
+		signal.Notify(summaryRequests, summarySignals...)
+	}
+
+	// Fires every report interval, or never if no report interval was given
+	var reportTicks <-chan time.Time
+
+	if reportInterval > 0 {
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+		reportTicks = ticker.C
+	}
+
+	// Redraws the dashboard, so that it stays up to date while waiting for requests
+	var dashboardTicks <-chan time.Time
+
+	if dash != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		dashboardTicks = ticker.C
+	}
+
+	// Amount of requests and total latency of every request since the last report
+	var windowRequests, windowFailed uint
+	var windowTotals []float64
+
+	// Results are aggregated and printed by this goroutine only, so no further synchronization is needed
+loop:
+	for {
+		var r result
+
+		select {
+		case <-reportTicks:
+			printReport(windowRequests, windowFailed, windowTotals)
+			windowRequests, windowFailed, windowTotals = 0, 0, nil
+
+			// Keep the metrics up to date during long runs
+			if prometheusFile != "" || pushgateway != "" {
+				exportPrometheus(summaries, false)
+			}
+			continue
+		case <-dashboardTicks:
+			dash.draw()
+			continue
+		case <-summaryRequests:
+			// Temporarily redirect the output, which is safe as it is only written to by this goroutine.
+			// CSV and InfluxDB output have no summary, so the text summary is printed instead.
+			stdout, format := out, output
+			out = os.Stderr
+
+			if output == outputCSV || output == outputInflux {
+				output = outputText
+			}
+
+			printSummaries(summaries)
+			out, output = stdout, format
+			continue
+		case received, ok := <-results:
+			// All workers have stopped
+			if !ok {
+				break loop
+			}
+			r = received
+		}
+
+		// Requests that finished after stopping because of --fail-fast are discarded
+		if failedFast {
+			continue
+		}
+
+		statistics, err := r.statistics, r.err
+
+		// The request itself succeeded, but the response did not meet the expectations
+		var expectationErr *expectationError
+
+		if errors.As(err, &expectationErr) && !statistics.Warmup {
+			unexpected++
+		}
+
+		// Stop all workers, the remaining results are discarded
+		if failFast && err != nil && !statistics.Warmup {
+			failedFast = true
+			cancel()
+		}
+
+		// Warmup requests are printed, but not counted towards the statistics
+		if !statistics.Warmup {
+			summaries[statistics.Target].add(statistics, err)
+			windowRequests++
+
+			if err != nil {
+				windowFailed++
+			} else if !(noNewConnCount && !*statistics.Reused) {
+				windowTotals = append(windowTotals, float64(*statistics.Total)/float64(time.Millisecond))
+			}
+		}
+
+		var errMsg string
+
+		if err != nil {
+			errMsg = err.Error()
+
+			// Trim: Get "https://example.com/": dial tcp: lookup example.com: no such host
+			// To: dial tcp: lookup example.com: no such host
+			var urlErr *url.Error
+
+			if errors.As(err, &urlErr) {
+				errMsg = urlErr.Err.Error()
+			}
+		}
+
+		if exporter != nil {
+			exporter.Export(statistics, errMsg)
+		}
 
-// Statistics stores all request statistics.
-// All pointer fields are optional. If a field is nil or an empty string, "N/A" is printed.
-type Statistics struct {
-	DNS          *time.Duration
-	Connect      *time.Duration
-	TLSHandshake *time.Duration
-	TTFB         *time.Duration
-	Download     *time.Duration
-	Total        *time.Duration
-	Reused       *bool
-	Proto        string
-	Status       string
-}
+		if alerts != nil && !statistics.Warmup {
+			alerts.observe(statistics, errMsg, summaries[statistics.Target])
+		}
 
-func main() {
-	flag.CommandLine.SortFlags = false
-	flag.Parse()
+		// The bell is written to stderr, so that it neither depends on colors nor ends up in the output
+		if (bell && err != nil) || (bellOnRecovery && err == nil && failing[statistics.Target]) {
+			fmt.Fprint(os.Stderr, "\a")
+		}
 
-	targetUrl = flag.Arg(0)
+		failing[statistics.Target] = err != nil
 
-	if targetUrl == "" {
-		fmt.Fprintln(os.Stderr, "Usage: httping [options] <url>")
-		flag.PrintDefaults()
-		os.Exit(-1)
+		// Quiet mode only prints the final statistics
+		if quiet {
+			printSkippedIntervals(statistics)
+			continue
+		}
+
+		if dash != nil {
+			dash.Add(statistics, errMsg)
+			continue
+		}
+
+		// Flood mode only prints its progress, the other formats are meant to be processed
+		if progress != nil {
+			progress.add(err)
+			continue
+		}
+
+		// Requests that failed before receiving a response have no headers to print
+		printHeaders := (verbose || verboseOnce && !printedHeaders) && statistics.Header != nil
+
+		if printHeaders {
+			printedHeaders = true
+		}
+
+		switch output {
+		case outputJSON:
+			result := newJSONResult(statistics, errMsg)
+
+			if printHeaders {
+				result.Header = statistics.Header
+				result.CertSubject = stringToPtr(statistics.CertSubject)
+				result.TransferEncoding = statistics.TransferEncoding
+
+				if statistics.ContentLength >= 0 {
+					result.ContentLength = &statistics.ContentLength
+				}
+			}
+
+			printJSON(result)
+		case outputCSV:
+			printCSVResult(statistics, errMsg)
+		case outputInflux:
+			printInfluxResult(statistics, errMsg)
+		case outputPing:
+			printPingResult(statistics, errMsg)
+
+			if printHeaders {
+				printResponseHeaders(statistics)
+			}
+		default:
+			var redirects string
+
+			if followRedirects {
+				redirects = fmt.Sprintf(" redirects=%s", formatInt(statistics.Redirects))
+			}
+
+			var tlsInfo string
+
+			if strings.HasPrefix(statistics.Target, "https://") {
+				tlsInfo = fmt.Sprintf(" tls_version=%s cipher=%s", formatString(statistics.TLSVersion), formatString(statistics.CipherSuite))
+
+				if certExpiry || certExpiryDays > 0 {
+					tlsInfo += fmt.Sprintf(" expiry=%s", formatPtrDays(statistics.certExpiryDays()))
+				}
+			}
+
+			var truncated string
+
+			if maxDownload > 0 {
+				truncated = fmt.Sprintf(" truncated=%s", formatString(strconv.FormatBool(statistics.Truncated)))
+			}
+
+			// Early hints are rare, so they are only shown if the server sent them
+			var hints string
+
+			if statistics.earlyHints() != nil {
+				hints = fmt.Sprintf(" hints=%s", formatPtrDuration(statistics.earlyHints()))
+			}
+
+			var slow string
+
+			if slowThreshold > 0 {
+				slow = fmt.Sprintf(" slow=%s", formatSlow(statistics.slow(errMsg)))
+			}
+
+			var addrs string
+
+			if showAddr {
+				addrs = fmt.Sprintf(" remote=%s local=%s", formatString(statistics.RemoteAddr), formatString(statistics.LocalAddr))
+			}
+
+			// The delay or rate changes over time when ramping
+			var ramped string
+
+			if delayRamp != nil {
+				ramped = fmt.Sprintf(" delay=%s", formatPtrDuration(statistics.Delay))
+			} else if rateRamp != nil {
+				ramped = fmt.Sprintf(" rate=%s", formatRate(statistics.Rate))
+			}
+
+			var retried string
+
+			if retries > 0 {
+				retried = fmt.Sprintf(" retries=%s", formatInt(statistics.Retries))
+			}
+
+			if timestamp {
+				fmt.Fprintf(out, "%s ", statistics.Start.Format(timestampFormat))
+			}
+
+			if statistics.Warmup {
+				fmt.Fprint(out, "(warmup) ")
+			}
+
+			// Label the line if there are multiple targets
+			if len(targetUrls) > 1 {
+				fmt.Fprintf(out, "target=%s ", statistics.Target)
+			}
+
+			fmt.Fprintf(out, "dns=%s conn=%s tls=%s send=%s ttfb=%s server=%s dl=%s bytes=%s speed=%s total=%s reused=%s wait=%s proto=%s%s status=%s%s%s%s%s%s%s%s error=%s\n",
+				formatPtrDuration(statistics.DNS),
+				formatPtrDuration(statistics.Connect),
+				formatPtrDuration(statistics.TLSHandshake),
+				formatPtrDuration(statistics.Send),
+				formatPtrDuration(statistics.TTFB),
+				formatPtrDuration(statistics.Server),
+				formatPtrDuration(statistics.Download),
+				formatPtrInt64(statistics.Bytes),
+				formatPtrThroughput(statistics.throughput()),
+				formatLatency(statistics.Total),
+				formatPtrBool(statistics.Reused),
+				formatPtrDuration(statistics.ConnWait),
+				formatString(statistics.Proto),
+				tlsInfo,
+				formatString(statistics.Status),
+				hints,
+				slow,
+				addrs,
+				truncated,
+				redirects,
+				retried,
+				ramped,
+				formatErrMsg(errMsg),
+			)
+
+			if printHeaders {
+				printResponseHeaders(statistics)
+			}
+		}
+
+		printSkippedIntervals(statistics)
 	}
 
-	var tlsNextProto TLSNextProtoMap
+	if dash != nil {
+		dash.Close()
+	}
 
-	if disableHttp2 {
-		// Setting TLSNextProto to an empty map disables HTTP/2
-		tlsNextProto = TLSNextProtoMap{}
+	if progress != nil {
+		progress.finish()
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			DisableKeepAlives:  !enableKeepAlive,
-			DisableCompression: disableCompression,
-			TLSNextProto:       tlsNextProto,
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // Do not follow redirects
-		},
-		Timeout: time.Duration(timeout) * time.Millisecond,
+	// Check mode only reports through the exit code
+	if !check {
+		printSummaries(summaries)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	if prometheusFile != "" || pushgateway != "" {
+		exportPrometheus(summaries, true)
+	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	// Export the remaining traces, as os.Exit does not wait for them
+	if exporter != nil {
+		exporter.Shutdown()
+	}
 
-	// Asynchronously wait for an interruption
-	go func() {
-		<-c
-		cancel()
-	}()
+	// Wait for the alerts that are still being sent
+	if alerts != nil {
+		alerts.Wait()
+	}
+
+	// Amount of requests sent to all targets combined
+	var requests, failed uint
+
+	for i, targetUrl := range targetUrls {
+		// A URL given multiple times shares its statistics
+		if slices.Index(targetUrls, targetUrl) != i {
+			continue
+		}
+
+		requests += summaries[targetUrl].requests
+		failed += summaries[targetUrl].failed
+	}
 
-	// Amount of requests sent
-	var requests, successful, failed uint
+	// Close the file explicitly, as os.Exit does not run deferred functions
+	if file != nil {
+		file.Close()
+	}
+
+	if failedFast {
+		os.Exit(1)
+	}
+
+	// If a failure threshold is given, it decides the exit code on its own, including for unexpected status codes
+	if flag.CommandLine.Changed("fail-threshold") {
+		if requests > 0 && float64(failed)/float64(requests)*100 > failThreshold {
+			os.Exit(1)
+		}
+	} else if check {
+		// Check mode fails on any failed request, not only on unexpected status codes
+		if failed > 0 || requests == 0 {
+			os.Exit(1)
+		}
+	} else if unexpected > 0 {
+		os.Exit(1)
+	}
+}
 
-	// Slice of total latency of every request
-	var totals []float64
+// worker sends requests until the requested amount of requests has been started (or has succeeded with --success-count),
+// the program is interrupted or the requested duration has elapsed.
+// With --success-count, requests that are in flight in other workers may exceed the amount.
+// The first request is sent after the given offset, which staggers the workers with --interval.
+func worker(client *http.Client, ctx, stopCtx context.Context, limiter *rateLimiter, results chan<- result, started, succeeded *atomic.Uint64, offset time.Duration) {
+	// Start of the next interval, for --interval
+	next := time.Now().Add(offset)
+
+	if offset > 0 {
+		select {
+		case <-stopCtx.Done():
+			return // The program was interrupted or the requested duration has elapsed before the first request
+		case <-time.After(offset):
+		}
+	}
 
 	for {
-		statistics, err := sendRequest(client, ctx, targetUrl)
+		n := started.Add(1)
+
+		// The requested amount of requests (in addition to the warmup requests) has already been started
+		if count > 0 && n > uint64(warmup+count) {
+			return
+		}
+
+		// The requested amount of requests has already succeeded
+		if successCount > 0 && succeeded.Load() >= uint64(successCount) {
+			return
+		}
 
-		// The program was interrupted while sending the request, break out of the for loop
+		// Cycle through the targets, so that they are pinged in turn according to their weights
+		targetUrl := targetSchedule[(n-1)%uint64(len(targetSchedule))]
+
+		// The rate in effect for this request, which is shown on its line
+		var rampedRate *float64
+
+		if rateRamp != nil {
+			r := rateRamp.at(rate, n)
+			rampedRate = &r
+			limiter.SetRate(r)
+		}
+
+		// The program was interrupted or the requested duration has elapsed while waiting for the rate limiter
+		if limiter != nil && limiter.Wait(stopCtx) != nil {
+			return
+		}
+
+		// Requests 1, N+1, 2N+1, ... use a new connection. With --concurrency, the connections of all workers are closed.
+		if reconnectEvery > 0 && n > 1 && (n-1)%uint64(reconnectEvery) == 0 {
+			client.CloseIdleConnections()
+		}
+
+		// The delay is measured from here, so that it also covers retries and waiting for the main goroutine to take the result
+		requestStart := time.Now()
+
+		statistics, err := sendRequestWithRetries(client, ctx, stopCtx, targetUrl, n)
+
+		// The request was cancelled because of --fail-fast
 		if errors.Is(err, context.Canceled) {
-			break
+			return
 		}
 
-		requests++
+		// The first requests are warmup requests
+		statistics.Warmup = n <= uint64(warmup)
+		statistics.Rate = rampedRate
 
-		if err != nil {
-			failed++
-		} else {
-			successful++
+		wait := delay
 
-			// If noNewConnCount is enabled, only append if the connection was reused
-			if !(noNewConnCount && !*statistics.Reused) {
-				totals = append(totals, float64(*statistics.Total)/float64(time.Millisecond))
-			}
+		if delayRamp != nil {
+			ramped := rampedDelay(n)
+			statistics.Delay = &ramped
+			wait = ramped
 		}
 
-		var errMsg string
+		if err == nil && !statistics.Warmup {
+			succeeded.Add(1)
+		}
 
-		if err != nil {
-			errMsg = err.Error()
+		// Skip the intervals the request overran instead of catching up with a burst.
+		// They are reported by the main goroutine after the line of the request.
+		if interval > 0 {
+			next = next.Add(interval)
 
-			// Trim: Get "https://example.com/": dial tcp: lookup example.com: no such host
-			// To: dial tcp: lookup example.com: no such host
-			// TODO: Make this more strict?
-			if strings.HasPrefix(errMsg, "Get ") {
-				errMsg = errMsg[strings.Index(errMsg, ": ")+2:]
+			if overran := time.Since(next); overran >= 0 {
+				skipped := overran/interval + 1
+				next = next.Add(skipped * interval)
+				statistics.SkippedIntervals = int64(skipped)
 			}
 		}
 
-		fmt.Printf("dns=%s conn=%s tls=%s ttfb=%s dl=%s total=%s reused=%s proto=%s status=%s error=%s\n",
-			formatPtrDuration(statistics.DNS),
-			formatPtrDuration(statistics.Connect),
-			formatPtrDuration(statistics.TLSHandshake),
-			formatPtrDuration(statistics.TTFB),
-			formatPtrDuration(statistics.Download),
-			formatPtrDuration(statistics.Total),
-			formatPtrBool(statistics.Reused),
-			formatString(statistics.Proto),
-			formatString(statistics.Status),
-			formatErrMsg(errMsg),
-		)
-
-		// The requested amount of requests has been reached, break out of the for loop
-		if requests == count {
-			break
+		results <- result{statistics, err}
+
+		// The requested amount of requests has been reached, or the requested duration has elapsed
+		if (count > 0 && started.Load() >= uint64(warmup+count)) || (successCount > 0 && succeeded.Load() >= uint64(successCount)) || stopCtx.Err() != nil {
+			return
+		}
+
+		// The rate limiter takes care of pacing the requests instead
+		if limiter != nil {
+			continue
+		}
+
+		if interval > 0 {
+			select {
+			case <-stopCtx.Done():
+				return // The program was interrupted or the requested duration has elapsed while sleeping
+			case <-time.After(time.Until(next)):
+			}
+
+			continue
 		}
 
-		done := false
+		// Spread the requests of multiple instances, so that they do not synchronize into bursts.
+		// The global source is seeded randomly at startup and safe for concurrent use.
+		if delayJitter > 0 {
+			wait += time.Duration(float64(wait) * float64(delayJitter) / 100 * (2*rand.Float64() - 1))
+		}
 
 		select {
-		case <-ctx.Done():
-			done = true // The program was interrupted while sleeping, break out of the for loop
-		case <-time.After(max(time.Duration(delay)*time.Millisecond-*statistics.Total, 0)):
+		case <-stopCtx.Done():
+			return // The program was interrupted or the requested duration has elapsed while sleeping
+		case <-time.After(time.Until(requestStart.Add(wait))):
 		}
+	}
+}
 
-		if done {
-			break
+// dialWithDNSTimeout resolves the host separately with --dns-timeout, and then dials the addresses in turn until one succeeds
+func dialWithDNSTimeout(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// IP addresses do not have to be resolved
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+
+	// The lookup is still cancelled with the request
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	// Only the DNS hooks are kept, as the resolver connecting to the DNS server must not be reported as the connection
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil {
+		lookupCtx = httptrace.WithClientTrace(lookupCtx, &httptrace.ClientTrace{DNSStart: trace.DNSStart, DNSDone: trace.DNSDone})
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(lookupCtx, strings.Replace(network, "tcp", "ip", 1), host)
+
+	// Only the lookup timed out, not the whole request
+	if err != nil && lookupCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return nil, &net.DNSError{Err: fmt.Sprintf("timed out after --dns-timeout of %s", dnsTimeout), Name: host, IsTimeout: true}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+
+		if err == nil {
+			return conn, nil
 		}
 	}
 
-	min_, _ := stats.Min(totals)
-	max_, _ := stats.Max(totals)
-	average, _ := stats.Mean(totals)
+	return nil, err
+}
 
-	percentile99, _ := stats.Percentile(totals, 99)
-	percentile95, _ := stats.Percentile(totals, 95)
-	percentile90, _ := stats.Percentile(totals, 90)
-	percentile75, _ := stats.Percentile(totals, 75)
-	percentile50, _ := stats.Percentile(totals, 50)
+// sendRequestWithRetries sends a request and retries it up to --retries times if it failed.
+// Only the final attempt is returned, with the number of retries it took.
+func sendRequestWithRetries(client *http.Client, ctx, stopCtx context.Context, targetUrl string, index uint64) (*Statistics, error) {
+	statistics, err := probe(client, ctx, targetUrl, index)
 
-	fmt.Println()
-	fmt.Printf("Requests: %d (%d successful, %d failed)\n", requests, successful, failed)
+	for retry := 1; retry <= int(retries); retry++ {
+		// The request succeeded, or the program was interrupted while sending it
+		if err == nil || errors.Is(err, context.Canceled) {
+			break
+		}
 
-	if len(totals) > 0 {
-		fmt.Println()
-		fmt.Printf("Min: %.1fms\n", min_)
-		fmt.Printf("Max: %.1fms\n", max_)
-		fmt.Printf("Average: %.1fms\n", average)
+		select {
+		case <-stopCtx.Done():
+			return statistics, err // The program was interrupted or the requested duration has elapsed, report the last attempt
+		case <-time.After(retryBackoff << (retry - 1)):
+		}
 
-		fmt.Println()
-		fmt.Printf("99th Percentile: %.1fms\n", percentile99)
-		fmt.Printf("95th Percentile: %.1fms\n", percentile95)
-		fmt.Printf("90th Percentile: %.1fms\n", percentile90)
-		fmt.Printf("75th Percentile: %.1fms\n", percentile75)
-		fmt.Printf("50th Percentile: %.1fms\n", percentile50)
+		statistics, err = probe(client, ctx, targetUrl, index)
+		statistics.Retries = retry
 	}
+
+	return statistics, err
 }
 
-func sendRequest(client *http.Client, ctx context.Context, targetUrl string) (*Statistics, error) {
-	statistics := &Statistics{}
+// sendRequest sends a single request. The index is the number of the request, starting at 1.
+func sendRequest(client *http.Client, ctx context.Context, targetUrl string, index uint64) (*Statistics, error) {
 	startTime := time.Now()
+	statistics := &Statistics{Target: targetUrl, Seq: index, Start: startTime}
 
 	defer func() {
-		diff := time.Now().Sub(startTime)
-		statistics.Total = &diff
+		// With --no-download, the total is taken before draining the body
+		if statistics.Total == nil {
+			diff := time.Now().Sub(startTime)
+			statistics.Total = &diff
+		}
 	}()
 
-	var dnsStart, connectStart, tlsHandshakeStart time.Time
+	// Time since the start when the request was completely written, or 0 if it was not.
+	// Over HTTP/2, the request is written by another goroutine than the one reading the response,
+	// so it is stored atomically and only used to calculate Send and Server once client.Do has returned.
+	var wroteRequest atomic.Int64
 
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			dnsStart = time.Now()
+			statistics.DNSStart = time.Now()
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			diff := time.Now().Sub(dnsStart)
+			diff := time.Now().Sub(statistics.DNSStart)
 			statistics.DNS = &diff
 		},
 		ConnectStart: func(network, addr string) {
-			connectStart = time.Now()
+			statistics.ConnectStart = time.Now()
 		},
 		ConnectDone: func(network, addr string, err error) {
-			diff := time.Now().Sub(connectStart)
+			diff := time.Now().Sub(statistics.ConnectStart)
 			statistics.Connect = &diff
 		},
 		TLSHandshakeStart: func() {
-			tlsHandshakeStart = time.Now()
+			statistics.TLSHandshakeStart = time.Now()
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			diff := time.Now().Sub(tlsHandshakeStart)
+			diff := time.Now().Sub(statistics.TLSHandshakeStart)
 			statistics.TLSHandshake = &diff
+
+			if err == nil {
+				statistics.setTLSState(state)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			wroteRequest.Store(int64(time.Now().Sub(startTime)))
 		},
 		GotFirstResponseByte: func() {
 			diff := time.Now().Sub(startTime)
 			statistics.TTFB = &diff
 		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			statistics.Informational = append(statistics.Informational, informationalResponse{code, header, time.Now().Sub(startTime)})
+			return nil
+		},
+		GetConn: func(hostPort string) {
+			statistics.GetConnStart = time.Now()
+		},
 		GotConn: func(info httptrace.GotConnInfo) {
 			statistics.Reused = &info.Reused
+			statistics.RemoteAddr = info.Conn.RemoteAddr().String()
+			statistics.LocalAddr = info.Conn.LocalAddr().String()
+
+			// Includes dialing a new connection, or waiting for an idle one if the pool is exhausted
+			diff := time.Now().Sub(statistics.GetConnStart)
+			statistics.ConnWait = &diff
+
+			// The request is written to the connection right away
+			statistics.SendStart = time.Now()
 		},
 	}
 
-	// Make a new GET request with the client trace
-	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", targetUrl, nil)
+	var bodyReader io.Reader
+
+	// A new reader is needed for every request, as the previous one has been consumed.
+	// NewRequestWithContext sets Content-Length automatically for a *bytes.Reader.
+	if requestBody != nil {
+		bodyReader = bytes.NewReader(requestBody)
+	}
+
+	// Make a new request with the client trace
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, targetUrl, bodyReader)
 
+	// The error is not wrapped, as the parse error would otherwise be trimmed down to its cause like the errors of client.Do
 	if err != nil {
-		return statistics, err
+		return statistics, fmt.Errorf("invalid URL: %s", err)
+	}
+
+	if host != "" {
+		req.Host = host
+	}
+
+	if cacheBust {
+		req.URL.RawQuery = appendCacheBust(req.URL.RawQuery)
 	}
 
+	// An empty value prevents Go from sending its default User-Agent
 	req.Header.Set("User-Agent", userAgent)
 
+	if user != "" {
+		// Without a colon, the whole value is the username and the password is empty
+		username, password, _ := strings.Cut(user, ":")
+		req.SetBasicAuth(username, password)
+	}
+
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	// Custom headers take precedence over the default ones
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
 	// Send the request
 	res, err := client.Do(req)
 
+	// The request may not have been written completely if the server responded early, or the request failed
+	wrote := time.Duration(wroteRequest.Load())
+
+	// HTTP/3 does not report connections to the trace, so the start of sending is unknown
+	if wrote > 0 && !statistics.SendStart.IsZero() {
+		diff := wrote - statistics.SendStart.Sub(startTime)
+		statistics.Send = &diff
+	}
+
 	if err != nil {
 		return statistics, err
 	}
 
 	defer res.Body.Close()
 
+	// GotFirstResponseByte fired for the first 1xx response, but the headers of the final response have just been received
+	if len(statistics.Informational) > 0 {
+		diff := time.Now().Sub(startTime)
+		statistics.TTFB = &diff
+	}
+
+	// The time the server took from receiving the whole request until the response started
+	if wrote > 0 && statistics.TTFB != nil {
+		diff := *statistics.TTFB - wrote
+		statistics.Server = &diff
+	}
+
+	// HTTP/3 does not report connections to the trace, but every new connection performs a handshake
+	if useHttp3 && statistics.Reused == nil {
+		reused := statistics.TLSHandshake == nil
+		statistics.Reused = &reused
+	}
+
 	statistics.Proto = res.Proto
+
+	// Reused connections do not perform a handshake, but the response still contains the negotiated parameters
+	if res.TLS != nil {
+		statistics.setTLSState(*res.TLS)
+	}
+	statistics.Header = res.Header
+	statistics.TransferEncoding = res.TransferEncoding
+	statistics.ContentLength = res.ContentLength
 	statistics.Status = res.Status
+	statistics.StatusCode = res.StatusCode
+
+	// Every followed redirect links the request to the response that caused it
+	for r := res.Request; r.Response != nil; r = r.Response.Request {
+		statistics.Redirects++
+	}
+
+	// HEAD responses have no body, so there is nothing to download
+	if req.Method == http.MethodHead {
+		return statistics, checkResponse(res, nil)
+	}
+
+	// Closing an unread HTTP/1.1 body prevents the connection from being reused, so up to 64 KiB of it are discarded
+	// after taking the total time. Larger bodies still close the connection, HTTP/2 only resets the stream.
+	if noDownload {
+		diff := time.Now().Sub(startTime)
+		statistics.Total = &diff
+
+		io.CopyN(io.Discard, res.Body, 64<<10)
+		return statistics, checkResponse(res, nil)
+	}
+
+	statistics.DownloadStart = time.Now()
+
+	// The body is only kept if it has to be matched against --expect-body
+	var dst io.Writer = io.Discard
+	var body limitedBuffer
+
+	if expectedBody != nil {
+		body.limit = int(expectBodyLimit)
+		dst = &body
+	}
+
+	// The body is streamed to the file, so that large bodies are not kept in memory
+	if saveBody != "" {
+		name := fmt.Sprintf("%s-%06d-%d.body", startTime.Format("20060102-150405.000"), index, res.StatusCode)
+		file, err := os.Create(filepath.Join(saveBody, name))
+
+		if err != nil {
+			return statistics, fmt.Errorf("failed to save body: %w", err)
+		}
+
+		defer file.Close()
+
+		dst = io.MultiWriter(dst, file)
+	}
+
+	var src io.Reader = res.Body
 
-	downloadStart := time.Now()
+	if maxDownload > 0 {
+		src = io.LimitReader(res.Body, int64(maxDownload))
+	}
 
-	_, err = io.Copy(io.Discard, res.Body)
+	n, err := io.Copy(dst, src)
 
+	// net/http does not reuse a connection whose body failed partway (e.g. a truncated response),
+	// so the next request opens a new connection and reports reused=false
 	if err != nil {
 		return statistics, err
 	}
 
-	diff := time.Now().Sub(downloadStart)
+	// The body is truncated if there is anything left after the limit. The connection can then not be reused.
+	if maxDownload > 0 && n == int64(maxDownload) {
+		_, err := io.ReadFull(res.Body, make([]byte, 1))
+		statistics.Truncated = err == nil
+	}
+
+	diff := time.Now().Sub(statistics.DownloadStart)
 	statistics.Download = &diff
-	return statistics, nil
+	statistics.Bytes = &n
+	return statistics, checkResponse(res, body.Bytes())
 }
 
 const (
 	reset  = "\u001B[0m"
 	red    = "\u001B[91m"
 	green  = "\u001B[92m"
+	yellow = "\u001B[93m"
 	format = "%s%-9s%s"
 )
 
+// color returns the given escape code, or an empty string if colors are disabled
+func color(code string) string {
+	if noColor {
+		return ""
+	}
+	return code
+}
+
+// isTerminal reports whether the given file is a terminal (character device)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func formatPtrDuration(duration *time.Duration) string {
 	if duration == nil {
-		return fmt.Sprintf(format, red, "N/A", reset)
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
+	}
+	return fmt.Sprintf(format, color(green), fmt.Sprintf("%.1fms", float64(*duration)/float64(time.Millisecond)), color(reset))
+}
+
+// formatLatency formats the total latency like formatPtrDuration, colored by --warn-latency and --crit-latency
+func formatLatency(duration *time.Duration) string {
+	if duration == nil {
+		return formatPtrDuration(duration)
+	}
+	return fmt.Sprintf(format, color(latencyColor(*duration)), fmt.Sprintf("%.1fms", float64(*duration)/float64(time.Millisecond)), color(reset))
+}
+
+// latencyColor returns the color of a latency according to --warn-latency and --crit-latency
+func latencyColor(duration time.Duration) string {
+	switch {
+	case critLatency > 0 && duration > critLatency:
+		return red
+	case warnLatency > 0 && duration > warnLatency, slowThreshold > 0 && duration > slowThreshold:
+		return yellow
+	default:
+		return green
 	}
-	return fmt.Sprintf(format, green, fmt.Sprintf("%.1fms", float64(*duration)/float64(time.Millisecond)), reset)
 }
 
 func formatPtrBool(b *bool) string {
 	if b == nil {
-		return fmt.Sprintf(format, red, "N/A", reset)
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
 	} else if *b {
-		return fmt.Sprintf(format, green, strconv.FormatBool(*b), reset)
+		return fmt.Sprintf(format, color(green), strconv.FormatBool(*b), color(reset))
 	} else {
-		return fmt.Sprintf(format, red, strconv.FormatBool(*b), reset)
+		return fmt.Sprintf(format, color(red), strconv.FormatBool(*b), color(reset))
 	}
 }
 
 func formatString(s string) string {
 	if s == "" {
-		return fmt.Sprintf(format, red, "N/A", reset)
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
+	}
+	return fmt.Sprintf(format, color(green), s, color(reset))
+}
+
+func formatPtrInt64(i *int64) string {
+	if i == nil {
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
+	}
+	return fmt.Sprintf(format, color(green), strconv.FormatInt(*i, 10), color(reset))
+}
+
+func formatPtrThroughput(mbps *float64) string {
+	if mbps == nil {
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
+	}
+	return fmt.Sprintf(format, color(green), fmt.Sprintf("%.1fMB/s", *mbps), color(reset))
+}
+
+// formatPtrDays colors the days until the certificate expires red if it expires within the threshold,
+// or today or earlier regardless of the threshold
+func formatPtrDays(days *int) string {
+	if days == nil {
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
+	} else if *days <= 0 || *days < int(certExpiryDays) {
+		return fmt.Sprintf(format, color(red), fmt.Sprintf("%dd", *days), color(reset))
+	}
+	return fmt.Sprintf(format, color(green), fmt.Sprintf("%dd", *days), color(reset))
+}
+
+func formatSlow(slow bool) string {
+	if slow {
+		return fmt.Sprintf(format, color(yellow), "true", color(reset))
+	}
+	return fmt.Sprintf(format, color(green), "false", color(reset))
+}
+
+func formatInt(i int) string {
+	return fmt.Sprintf(format, color(green), strconv.Itoa(i), color(reset))
+}
+
+func formatRate(rate *float64) string {
+	if rate == nil {
+		return fmt.Sprintf(format, color(red), "N/A", color(reset))
 	}
-	return fmt.Sprintf(format, green, s, reset)
+	return fmt.Sprintf(format, color(green), fmt.Sprintf("%.1f/s", *rate), color(reset))
 }
 
 func formatErrMsg(s string) string {
 	if s == "" {
-		return fmt.Sprintf(format, green, "N/A", reset)
+		return fmt.Sprintf(format, color(green), "N/A", color(reset))
 	}
-	return fmt.Sprintf(format, red, s, reset)
+	return fmt.Sprintf(format, color(red), s, color(reset))
 }
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMain runs httping itself instead of the tests if HTTPING_TEST_ARGS is set, see runHttping
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("HTTPING_TEST_ARGS"); ok {
+		os.Args = append([]string{"httping"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runHttping runs httping with the given arguments in a new process, as main() exits, and returns its stderr and exit code
+func runHttping(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "HTTPING_TEST_ARGS="+strings.Join(args, "\n"))
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return stderr.String(), exitErr.ExitCode()
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stderr.String(), 0
+}
+
+func TestTruncatedBodyIsNotReused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Declare a longer body than is sent, so that the client runs into an unexpected EOF
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("truncated"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	statistics, err := sendRequest(client, context.Background(), server.URL, 1)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected an unexpected EOF, got %v", err)
+	}
+
+	if statistics.Reused == nil || *statistics.Reused {
+		t.Fatalf("expected the first request to use a new connection")
+	}
+
+	statistics, _ = sendRequest(client, context.Background(), server.URL, 2)
+
+	if statistics.Reused == nil || *statistics.Reused {
+		t.Fatalf("expected the connection of the truncated response not to be reused")
+	}
+}
+
+// runWorker sends requests to the server with a single worker and returns their statistics
+func runWorker(t *testing.T, serverUrl string, n uint, d time.Duration) []*Statistics {
+	t.Helper()
+
+	previousCount, previousDelay, previousSchedule := count, delay, targetSchedule
+	count, delay, targetSchedule = n, d, []string{serverUrl}
+
+	t.Cleanup(func() {
+		count, delay, targetSchedule = previousCount, previousDelay, previousSchedule
+	})
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	results := make(chan result)
+	var started, succeeded atomic.Uint64
+
+	go func() {
+		worker(client, context.Background(), context.Background(), nil, results, &started, &succeeded, 0)
+		close(results)
+	}()
+
+	var statistics []*Statistics
+
+	for r := range results {
+		if r.err != nil {
+			t.Fatalf("request failed: %s", r.err)
+		}
+
+		statistics = append(statistics, r.statistics)
+	}
+
+	if len(statistics) != int(n) {
+		t.Fatalf("expected %d requests, got %d", n, len(statistics))
+	}
+
+	return statistics
+}
+
+func TestDelayIsNotIncludedInTotal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	const d = 100 * time.Millisecond
+	statistics := runWorker(t, server.URL, 3, d)
+
+	for i, s := range statistics {
+		if *s.Total >= d {
+			t.Errorf("request %d: total %s includes the delay of %s", i+1, *s.Total, d)
+		}
+
+		// Requests start the delay apart, regardless of how long they took
+		if i > 0 {
+			if gap := s.Start.Sub(statistics[i-1].Start); gap < d {
+				t.Errorf("request %d started %s after the previous one, expected at least %s", i+1, gap, d)
+			}
+		}
+	}
+}
+
+func TestOverrunStartsNextRequestImmediately(t *testing.T) {
+	const took = 150 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(took)
+	}))
+	defer server.Close()
+
+	statistics := runWorker(t, server.URL, 3, 50*time.Millisecond)
+
+	for i := 1; i < len(statistics); i++ {
+		previous := statistics[i-1]
+
+		// The next request starts right after the previous one ends, apart from scheduling overhead
+		if idle := statistics[i].Start.Sub(previous.Start.Add(*previous.Total)); idle > 30*time.Millisecond {
+			t.Errorf("request %d started %s after the previous one ended, expected immediately", i+1, idle)
+		}
+	}
+}
+
+func TestNoDownloadReusesConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+
+	previousNoDownload := noDownload
+	noDownload = true
+
+	t.Cleanup(func() {
+		noDownload = previousNoDownload
+	})
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	for i := uint64(1); i <= 2; i++ {
+		statistics, err := sendRequest(client, context.Background(), server.URL, i)
+
+		if err != nil {
+			t.Fatalf("request %d failed: %s", i, err)
+		}
+
+		if statistics.Download != nil {
+			t.Errorf("request %d: expected no download time with --no-download", i)
+		}
+
+		if reused := i > 1; statistics.Reused == nil || *statistics.Reused != reused {
+			t.Errorf("request %d: expected reused=%t", i, reused)
+		}
+	}
+}
+
+func TestExpectBodyRejectsHead(t *testing.T) {
+	for _, args := range [][]string{
+		{"--method", "HEAD", "--expect-body", "ok", "http://127.0.0.1:1/"},
+		{"--method", "head", "--expect-body", "ok", "http://127.0.0.1:1/"},
+	} {
+		stderr, code := runHttping(t, args...)
+
+		if expected := "--expect-body requires the response body, so it cannot be used with --method HEAD\n"; stderr != expected || code != 255 {
+			t.Errorf("%v: expected %q and exit code 255, got %q and %d", args, expected, stderr, code)
+		}
+	}
+}
+
+func TestInvalidUrlIsNotCountedAsSuccess(t *testing.T) {
+	const targetUrl = "http://[::1"
+
+	previousCount, previousSchedule := count, targetSchedule
+	count, targetSchedule = 1, []string{targetUrl}
+
+	t.Cleanup(func() {
+		count, targetSchedule = previousCount, previousSchedule
+	})
+
+	results := make(chan result, 1)
+	var started, succeeded atomic.Uint64
+
+	worker(&http.Client{Transport: &http.Transport{}}, context.Background(), context.Background(), nil, results, &started, &succeeded, 0)
+	r := <-results
+
+	if expected := `invalid URL: parse "http://[::1": missing ']' in host`; r.err == nil || r.err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, r.err)
+	}
+
+	if succeeded.Load() != 0 {
+		t.Errorf("expected no successful requests, got %d", succeeded.Load())
+	}
+
+	s := newSummary()
+	s.add(r.statistics, r.err)
+
+	if s.requests != 1 || s.successful != 0 || s.failed != 1 {
+		t.Errorf("expected 1 failed request, got %d requests, %d successful and %d failed", s.requests, s.successful, s.failed)
+	}
+}
+
+func TestFormatPtrDays(t *testing.T) {
+	previousNoColor, previousThreshold := noColor, certExpiryDays
+	noColor = false
+
+	t.Cleanup(func() {
+		noColor, certExpiryDays = previousNoColor, previousThreshold
+	})
+
+	tests := []struct {
+		threshold uint
+		days      int
+		color     string
+	}{
+		{0, -1, red},
+		{0, 0, red},
+		{0, 1, green},
+		{7, 0, red},
+		{7, 6, red},
+		{7, 7, green},
+		{7, 8, green},
+	}
+
+	for _, test := range tests {
+		certExpiryDays = test.threshold
+
+		if actual := formatPtrDays(&test.days); !strings.HasPrefix(actual, test.color) {
+			t.Errorf("threshold %d, %d days: expected color %q, got %q", test.threshold, test.days, test.color, actual)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otelBatchSize is the maximum number of requests exported at once
+const otelBatchSize = 100
+
+// otelFlushInterval is how long requests are collected before they are exported
+const otelFlushInterval = 5 * time.Second
+
+// otelWarningInterval is the minimum time between warnings about dropped requests
+const otelWarningInterval = 10 * time.Second
+
+// otelExporter exports every request as an OpenTelemetry trace over OTLP/HTTP (JSON encoding).
+// Every request is a client span, with a child span for every phase that happened.
+type otelExporter struct {
+	url     string
+	client  *http.Client
+	results chan otelResult
+	done    chan struct{}
+
+	// Requests dropped since the last warning, only accessed by the goroutine calling Export
+	dropped     uint
+	lastWarning time.Time
+}
+
+type otelResult struct {
+	statistics *Statistics
+	errMsg     string
+}
+
+// newOtelExporter starts exporting to the given OTLP/HTTP endpoint (e.g. http://localhost:4318)
+func newOtelExporter(endpoint string) *otelExporter {
+	// The path is only appended if the endpoint does not contain it already, like the OpenTelemetry SDKs do
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+
+	e := &otelExporter{
+		url:     endpoint,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		results: make(chan otelResult, otelBatchSize),
+		done:    make(chan struct{}),
+	}
+
+	go e.run()
+	return e
+}
+
+// Export queues a request to be exported. If the queue is full because the endpoint is slow, the request is dropped
+// instead of delaying the output, with a warning at most every otelWarningInterval.
+func (e *otelExporter) Export(statistics *Statistics, errMsg string) {
+	select {
+	case e.results <- otelResult{statistics, errMsg}:
+	default:
+		e.dropped++
+
+		if time.Since(e.lastWarning) >= otelWarningInterval {
+			fmt.Fprintf(os.Stderr, "Warning: the OpenTelemetry endpoint is too slow, dropped %d request(s)\n", e.dropped)
+			e.dropped = 0
+			e.lastWarning = time.Now()
+		}
+	}
+}
+
+// Shutdown exports the queued requests and waits until they have been sent
+func (e *otelExporter) Shutdown() {
+	close(e.results)
+	<-e.done
+}
+
+func (e *otelExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otelFlushInterval)
+	defer ticker.Stop()
+
+	var batch []otelResult
+
+	for {
+		select {
+		case r, ok := <-e.results:
+			if !ok {
+				e.flush(batch)
+				return
+			}
+
+			batch = append(batch, r)
+
+			if len(batch) >= otelBatchSize {
+				e.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+// flush exports the batch. Errors are only reported, so that they do not interrupt pinging.
+func (e *otelExporter) flush(batch []otelResult) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var spans []*otlpSpan
+
+	for _, r := range batch {
+		spans = append(spans, newOtlpSpans(r.statistics, r.errMsg)...)
+	}
+
+	body, err := json.Marshal(&otlpRequest{
+		ResourceSpans: []*otlpResourceSpans{{
+			Resource: &otlpResource{Attributes: []*otlpAttribute{stringAttribute("service.name", "httping")}},
+			ScopeSpans: []*otlpScopeSpans{{
+				Scope: &otlpScope{Name: "httping"},
+				Spans: spans,
+			}},
+		}},
+	})
+
+	if err == nil {
+		err = e.post(body)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export traces: %s\n", err)
+	}
+}
+
+func (e *otelExporter) post(body []byte) error {
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// newOtlpSpans returns the span of the request, followed by the spans of its phases
+func newOtlpSpans(statistics *Statistics, errMsg string) []*otlpSpan {
+	traceId := randomHex(16)
+
+	root := &otlpSpan{
+		TraceId:   traceId,
+		SpanId:    randomHex(8),
+		Name:      method,
+		Kind:      otlpSpanKindClient,
+		StartTime: formatUnixNano(statistics.Start),
+		EndTime:   formatUnixNano(statistics.Start.Add(*statistics.Total)),
+		Attributes: []*otlpAttribute{
+			stringAttribute("http.request.method", method),
+			stringAttribute("url.full", statistics.Target),
+			boolAttribute("httping.warmup", statistics.Warmup),
+		},
+	}
+
+	if statistics.StatusCode != 0 {
+		root.Attributes = append(root.Attributes, intAttribute("http.response.status_code", statistics.StatusCode))
+	}
+
+	// E.g. HTTP/2.0 is the protocol http with version 2.0
+	if name, version, found := strings.Cut(statistics.Proto, "/"); found {
+		root.Attributes = append(root.Attributes,
+			stringAttribute("network.protocol.name", strings.ToLower(name)),
+			stringAttribute("network.protocol.version", version),
+		)
+	}
+
+	if statistics.Reused != nil {
+		root.Attributes = append(root.Attributes, boolAttribute("httping.connection.reused", *statistics.Reused))
+	}
+
+	if errMsg != "" {
+		root.Status = &otlpStatus{Code: otlpStatusCodeError, Message: errMsg}
+	}
+
+	spans := []*otlpSpan{root}
+	starts := statistics.phaseStarts()
+
+	for i, phase := range statistics.phases() {
+		// The phase did not happen (e.g. TLS for plain HTTP)
+		if phase == nil {
+			continue
+		}
+
+		spans = append(spans, &otlpSpan{
+			TraceId:      traceId,
+			SpanId:       randomHex(8),
+			ParentSpanId: root.SpanId,
+			Name:         phaseNames[i],
+			Kind:         otlpSpanKindInternal,
+			StartTime:    formatUnixNano(starts[i]),
+			EndTime:      formatUnixNano(starts[i].Add(*phase)),
+		})
+	}
+
+	return spans
+}
+
+// randomHex returns n random bytes in hex, as used for trace and span IDs in OTLP/JSON
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func formatUnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusCodeError  = 2
+)
+
+// otlpRequest is the JSON representation of an OTLP ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   *otlpResource     `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope *otlpScope  `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId      string           `json:"traceId"`
+	SpanId       string           `json:"spanId"`
+	ParentSpanId string           `json:"parentSpanId,omitempty"`
+	Name         string           `json:"name"`
+	Kind         int              `json:"kind"`
+	StartTime    string           `json:"startTimeUnixNano"`
+	EndTime      string           `json:"endTimeUnixNano"`
+	Attributes   []*otlpAttribute `json:"attributes,omitempty"`
+	Status       *otlpStatus      `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an AnyValue, of which only one field is set
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func stringAttribute(key, value string) *otlpAttribute {
+	return &otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) *otlpAttribute {
+	// 64-bit integers are encoded as strings in OTLP/JSON
+	s := strconv.Itoa(value)
+	return &otlpAttribute{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func boolAttribute(key string, value bool) *otlpAttribute {
+	return &otlpAttribute{Key: key, Value: otlpValue{BoolValue: &value}}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// out is where all regular output is written to.
+// With --output-file, it writes to both stdout and the file.
+var out io.Writer = os.Stdout
+
+// colorRegexp matches the ANSI escape codes used for colors
+var colorRegexp = regexp.MustCompile("\u001B\\[[0-9;]*m")
+
+// stripColorWriter removes all colors from the output before writing it to the underlying writer
+type stripColorWriter struct {
+	w io.Writer
+}
+
+func (s *stripColorWriter) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(colorRegexp.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	// Report the original length, as callers (e.g. io.MultiWriter) expect all of p to be written
+	return len(p), nil
+}
+
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputCSV    = "csv"
+	outputInflux = "influx"
+	outputPing   = "ping"
+
+	// outputNDJSON is an alias of outputJSON, which already prints one compact object per line
+	outputNDJSON = "ndjson"
+)
+
+var outputs = []string{outputText, outputJSON, outputNDJSON, outputCSV, outputInflux, outputPing}
+
+// jsonResult is the JSON representation of a single request.
+// Fields that are not available are encoded as null.
+type jsonResult struct {
+	Type         string   `json:"type"`
+	Target       string   `json:"target"`
+	Timestamp    string   `json:"timestamp"`
+	DNS          *float64 `json:"dns_ms"`
+	Connect      *float64 `json:"conn_ms"`
+	TLSHandshake *float64 `json:"tls_ms"`
+	Send         *float64 `json:"send_ms"`
+	TTFB         *float64 `json:"ttfb_ms"`
+	Server       *float64 `json:"server_ms"`
+	EarlyHints   *float64 `json:"early_hints_ms"`
+	Download     *float64 `json:"download_ms"`
+	Bytes        *int64   `json:"bytes"`
+	Throughput   *float64 `json:"throughput_mb_s"`
+	Truncated    bool     `json:"truncated"`
+	Total        *float64 `json:"total_ms"`
+	Reused       *bool    `json:"reused"`
+	ConnWait     *float64 `json:"conn_wait_ms"`
+	RemoteAddr   *string  `json:"remote_addr"`
+	LocalAddr    *string  `json:"local_addr"`
+	Proto        *string  `json:"proto"`
+	TLSVersion   *string  `json:"tls_version"`
+	CipherSuite  *string  `json:"cipher"`
+	CertExpiry   *int     `json:"cert_expiry_days"`
+	Status       *string  `json:"status"`
+	Redirects    int      `json:"redirects"`
+	Retries      int      `json:"retries"`
+	Delay        *float64 `json:"delay_ms"`
+	Rate         *float64 `json:"rate_per_s"`
+	Slow         bool     `json:"slow"`
+	Warmup       bool     `json:"warmup"`
+	Error        *string  `json:"error"`
+
+	// Header, CertSubject, TransferEncoding and ContentLength are only set with --verbose or --verbose-once
+	Header           http.Header `json:"headers,omitempty"`
+	CertSubject      *string     `json:"cert_subject,omitempty"`
+	TransferEncoding []string    `json:"transfer_encoding,omitempty"`
+	ContentLength    *int64      `json:"content_length,omitempty"`
+}
+
+// jsonSummary is the JSON representation of the final statistics.
+// The latency fields are null if no requests were counted towards the statistics.
+type jsonSummary struct {
+	Type              string   `json:"type"`
+	Target            string   `json:"target"`
+	Requests          uint     `json:"requests"`
+	Successful        uint     `json:"successful"`
+	Failed            uint     `json:"failed"`
+	ReuseRate         *float64 `json:"reuse_rate_pct"`
+	Samples           int      `json:"samples"`
+	Min               *float64 `json:"min_ms"`
+	Max               *float64 `json:"max_ms"`
+	Average           *float64 `json:"avg_ms"`
+	GeometricMean     *float64 `json:"geomean_ms"`
+	StandardDeviation *float64 `json:"stddev_ms"`
+	Jitter            *float64 `json:"jitter_ms"`
+	Percentile99      *float64 `json:"p99_ms"`
+	Percentile95      *float64 `json:"p95_ms"`
+	Percentile90      *float64 `json:"p90_ms"`
+	Percentile75      *float64 `json:"p75_ms"`
+	Percentile50      *float64 `json:"p50_ms"`
+	TTFBPercentile99  *float64 `json:"ttfb_p99_ms"`
+	TTFBPercentile95  *float64 `json:"ttfb_p95_ms"`
+	TTFBPercentile50  *float64 `json:"ttfb_p50_ms"`
+	Throughput        *float64 `json:"avg_throughput_mb_s"`
+	TotalBytes        int64    `json:"total_bytes"`
+	OverallThroughput *float64 `json:"overall_throughput_mb_s"`
+
+	// Percentiles maps every percentile given by --percentiles (e.g. "99.9") to its latency
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+
+	// Statuses maps every status (e.g. "200 OK") to the number of responses with it
+	Statuses map[string]uint `json:"statuses"`
+
+	// Errors maps the reason of every failed request (e.g. "timeout", see classifyError) to the number of requests
+	Errors map[string]uint `json:"errors"`
+
+	// Slow is the number of successful requests that took longer than --slow-threshold, which is null without it
+	Slow *uint `json:"slow"`
+
+	// Longest and current number of consecutive failed requests
+	LongestFailureStreak uint `json:"longest_failure_streak"`
+	CurrentFailureStreak uint `json:"current_failure_streak"`
+
+	// Phases is only set with --phase-stats, phases that were never observed are omitted
+	Phases map[string]*jsonPhase `json:"phases,omitempty"`
+}
+
+// jsonReport is the JSON representation of the statistics of a single --report-interval.
+// The latency fields are null if no requests were counted towards the statistics.
+type jsonReport struct {
+	Type         string   `json:"type"`
+	Interval     float64  `json:"interval_s"`
+	Requests     uint     `json:"requests"`
+	Failed       uint     `json:"failed"`
+	Average      *float64 `json:"avg_ms"`
+	Percentile95 *float64 `json:"p95_ms"`
+}
+
+// jsonPhase is the JSON representation of the statistics of a single phase
+type jsonPhase struct {
+	Min          float64 `json:"min_ms"`
+	Average      float64 `json:"avg_ms"`
+	Percentile95 float64 `json:"p95_ms"`
+}
+
+func printJSON(v any) {
+	// Encode writes a trailing newline, so every value ends up on its own line
+	_ = json.NewEncoder(out).Encode(v)
+}
+
+func newJSONResult(statistics *Statistics, errMsg string) *jsonResult {
+	return &jsonResult{
+		Type:         "request",
+		Target:       statistics.Target,
+		Timestamp:    statistics.Start.Format(time.RFC3339Nano),
+		DNS:          durationToMs(statistics.DNS),
+		Connect:      durationToMs(statistics.Connect),
+		TLSHandshake: durationToMs(statistics.TLSHandshake),
+		Send:         durationToMs(statistics.Send),
+		TTFB:         durationToMs(statistics.TTFB),
+		Server:       durationToMs(statistics.Server),
+		EarlyHints:   durationToMs(statistics.earlyHints()),
+		Download:     durationToMs(statistics.Download),
+		Bytes:        statistics.Bytes,
+		Throughput:   statistics.throughput(),
+		Truncated:    statistics.Truncated,
+		Total:        durationToMs(statistics.Total),
+		Reused:       statistics.Reused,
+		ConnWait:     durationToMs(statistics.ConnWait),
+		RemoteAddr:   stringToPtr(statistics.RemoteAddr),
+		LocalAddr:    stringToPtr(statistics.LocalAddr),
+		Proto:        stringToPtr(statistics.Proto),
+		TLSVersion:   stringToPtr(statistics.TLSVersion),
+		CipherSuite:  stringToPtr(statistics.CipherSuite),
+		CertExpiry:   statistics.certExpiryDays(),
+		Status:       stringToPtr(statistics.Status),
+		Redirects:    statistics.Redirects,
+		Retries:      statistics.Retries,
+		Delay:        durationToMs(statistics.Delay),
+		Rate:         statistics.Rate,
+		Slow:         statistics.slow(errMsg),
+		Warmup:       statistics.Warmup,
+		Error:        stringToPtr(errMsg),
+	}
+}
+
+func durationToMs(duration *time.Duration) *float64 {
+	if duration == nil {
+		return nil
+	}
+	ms := float64(*duration) / float64(time.Millisecond)
+	return &ms
+}
+
+func stringToPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// printResponseHeaders prints the status line and headers of a response below its line, like curl does with --verbose
+func printResponseHeaders(statistics *Statistics) {
+	// The subject of the certificate shows which one was served, e.g. to verify --sni
+	if statistics.CertSubject != "" {
+		fmt.Fprintf(out, "* Certificate: %s\n", statistics.CertSubject)
+	}
+
+	// 1xx responses precede the final response, like curl shows them
+	for _, r := range statistics.Informational {
+		fmt.Fprintf(out, "< %s %d %s\n", statistics.Proto, r.StatusCode, http.StatusText(r.StatusCode))
+
+		for _, name := range sortedHeaderNames(http.Header(r.Header)) {
+			for _, value := range r.Header[name] {
+				fmt.Fprintf(out, "< %s: %s\n", name, value)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "< %s %s\n", statistics.Proto, statistics.Status)
+
+	for _, name := range sortedHeaderNames(statistics.Header) {
+		for _, value := range statistics.Header[name] {
+			fmt.Fprintf(out, "< %s: %s\n", name, value)
+		}
+	}
+
+	// Go removes the Transfer-Encoding header, and the Content-Length of decompressed bodies
+	switch {
+	case len(statistics.TransferEncoding) > 0:
+		fmt.Fprintf(out, "* Body: %s\n", strings.Join(statistics.TransferEncoding, ", "))
+	case statistics.ContentLength >= 0:
+		fmt.Fprintf(out, "* Body: Content-Length %d\n", statistics.ContentLength)
+	default:
+		fmt.Fprintln(out, "* Body: unknown length")
+	}
+
+	// A body cut off by --max-download is expected to be shorter
+	if statistics.Bytes != nil && statistics.ContentLength >= 0 && *statistics.Bytes != statistics.ContentLength && !statistics.Truncated {
+		fmt.Fprintf(out, "* Warning: downloaded %d bytes, but the Content-Length is %d\n", *statistics.Bytes, statistics.ContentLength)
+	}
+
+	fmt.Fprintln(out)
+}
+
+func sortedHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+
+	for name := range header {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+var csvWriter *csv.Writer
+
+func printCSVHeader() {
+	csvWriter = csv.NewWriter(out)
+	_ = csvWriter.Write([]string{"timestamp", "target", "dns_ms", "conn_ms", "tls_ms", "send_ms", "ttfb_ms", "server_ms", "early_hints_ms", "download_ms", "bytes", "throughput_mb_s", "truncated", "total_ms", "reused", "conn_wait_ms", "remote_addr", "local_addr", "proto", "tls_version", "cipher", "cert_expiry_days", "status", "redirects", "retries", "slow", "warmup", "error"})
+	csvWriter.Flush()
+}
+
+// printCSVResult prints a single request as a CSV row.
+// Fields that are not available are left empty.
+func printCSVResult(statistics *Statistics, errMsg string) {
+	var bytes, throughput, expiry, reused string
+
+	if statistics.Bytes != nil {
+		bytes = strconv.FormatInt(*statistics.Bytes, 10)
+	}
+
+	if mbps := statistics.throughput(); mbps != nil {
+		throughput = strconv.FormatFloat(*mbps, 'f', -1, 64)
+	}
+
+	if days := statistics.certExpiryDays(); days != nil {
+		expiry = strconv.Itoa(*days)
+	}
+
+	if statistics.Reused != nil {
+		reused = strconv.FormatBool(*statistics.Reused)
+	}
+
+	_ = csvWriter.Write([]string{
+		statistics.Start.Format(time.RFC3339Nano),
+		statistics.Target,
+		formatCSVDuration(statistics.DNS),
+		formatCSVDuration(statistics.Connect),
+		formatCSVDuration(statistics.TLSHandshake),
+		formatCSVDuration(statistics.Send),
+		formatCSVDuration(statistics.TTFB),
+		formatCSVDuration(statistics.Server),
+		formatCSVDuration(statistics.earlyHints()),
+		formatCSVDuration(statistics.Download),
+		bytes,
+		throughput,
+		strconv.FormatBool(statistics.Truncated),
+		formatCSVDuration(statistics.Total),
+		reused,
+		formatCSVDuration(statistics.ConnWait),
+		statistics.RemoteAddr,
+		statistics.LocalAddr,
+		statistics.Proto,
+		statistics.TLSVersion,
+		statistics.CipherSuite,
+		expiry,
+		statistics.Status,
+		strconv.Itoa(statistics.Redirects),
+		strconv.Itoa(statistics.Retries),
+		strconv.FormatBool(statistics.slow(errMsg)),
+		strconv.FormatBool(statistics.Warmup),
+		errMsg,
+	})
+
+	// Flush after every row, so that the output can be consumed while httping is running
+	csvWriter.Flush()
+}
+
+func formatCSVDuration(duration *time.Duration) string {
+	if duration == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*durationToMs(duration), 'f', -1, 64)
+}
+
+// floodProgress prints the number of requests and failures of --flood to stderr instead of every request, like ping -f.
+// It is throttled, so that printing cannot slow down the flood. On a terminal, the line is updated in place.
+type floodProgress struct {
+	start    time.Time
+	printed  time.Time
+	interval time.Duration
+	terminal bool
+	requests uint64
+	failed   uint64
+}
+
+func newFloodProgress() *floodProgress {
+	p := &floodProgress{start: time.Now(), interval: time.Second, terminal: isTerminal(os.Stderr)}
+
+	if p.terminal {
+		p.interval = 100 * time.Millisecond
+	}
+
+	return p
+}
+
+func (p *floodProgress) add(err error) {
+	p.requests++
+
+	if err != nil {
+		p.failed++
+	}
+
+	if now := time.Now(); now.Sub(p.printed) >= p.interval {
+		p.printed = now
+		p.print(now)
+	}
+}
+
+func (p *floodProgress) print(now time.Time) {
+	line := fmt.Sprintf("%d requests, %d failed, %.0f/s", p.requests, p.failed, float64(p.requests)/now.Sub(p.start).Seconds())
+
+	if p.terminal {
+		fmt.Fprintf(os.Stderr, "\r%s\u001B[K", line)
+	} else {
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// finish prints the final progress and ends the line on a terminal
+func (p *floodProgress) finish() {
+	if p.requests == 0 {
+		return
+	}
+
+	p.print(time.Now())
+
+	if p.terminal {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// printSkippedIntervals warns on stderr if the request overran --interval, after the request itself has been printed
+func printSkippedIntervals(statistics *Statistics) {
+	if statistics.SkippedIntervals > 0 {
+		fmt.Fprintf(os.Stderr, "Request took %s, longer than --interval, skipped %d interval(s)\n", statistics.Total.Round(time.Millisecond), statistics.SkippedIntervals)
+	}
+}
+
+// printPingResult prints a single request like ping prints a reply, e.g. seq=3 status=200 time=45.2 ms
+func printPingResult(statistics *Statistics, errMsg string) {
+	fmt.Fprintln(out, formatPingResult(statistics, errMsg))
+}
+
+func formatPingResult(statistics *Statistics, errMsg string) string {
+	var b strings.Builder
+
+	if timestamp {
+		fmt.Fprintf(&b, "%s ", statistics.Start.Format(timestampFormat))
+	}
+
+	if statistics.Warmup {
+		b.WriteString("(warmup) ")
+	}
+
+	// Label the line if there are multiple targets, like ping labels replies with the host
+	if len(targetUrls) > 1 {
+		fmt.Fprintf(&b, "%s: ", statistics.Target)
+	}
+
+	fmt.Fprintf(&b, "seq=%d", statistics.Seq)
+
+	if statistics.StatusCode != 0 {
+		fmt.Fprintf(&b, " status=%d", statistics.StatusCode)
+	}
+
+	if errMsg != "" {
+		fmt.Fprintf(&b, " error=%s%s%s", color(red), errMsg, color(reset))
+	} else {
+		fmt.Fprintf(&b, " time=%s%.1f ms%s", color(latencyColor(*statistics.Total)), float64(*statistics.Total)/float64(time.Millisecond), color(reset))
+	}
+
+	// The delay or rate changes over time when ramping
+	if statistics.Delay != nil {
+		fmt.Fprintf(&b, " delay=%.1f ms", float64(*statistics.Delay)/float64(time.Millisecond))
+	} else if statistics.Rate != nil {
+		fmt.Fprintf(&b, " rate=%.1f/s", *statistics.Rate)
+	}
+
+	return b.String()
+}
+
+var influxTagReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+var influxStringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// printInfluxResult prints a single request in the InfluxDB line protocol.
+// Phases that did not happen are omitted, as the line protocol has no null values.
+func printInfluxResult(statistics *Statistics, errMsg string) {
+	tags := "httping"
+
+	if u, err := url.Parse(statistics.Target); err == nil {
+		tags += ",host=" + influxTagReplacer.Replace(u.Host)
+	}
+
+	if statistics.Proto != "" {
+		tags += ",proto=" + influxTagReplacer.Replace(statistics.Proto)
+	}
+
+	if statistics.StatusCode != 0 {
+		tags += ",status=" + strconv.Itoa(statistics.StatusCode)
+	}
+
+	var fields []string
+
+	for i, phase := range statistics.phases() {
+		if phase != nil {
+			fields = append(fields, fmt.Sprintf("%s=%s", phaseNames[i], strconv.FormatFloat(*durationToMs(phase), 'f', -1, 64)))
+		}
+	}
+
+	if statistics.Server != nil {
+		fields = append(fields, "server="+strconv.FormatFloat(*durationToMs(statistics.Server), 'f', -1, 64))
+	}
+
+	if hints := statistics.earlyHints(); hints != nil {
+		fields = append(fields, "early_hints="+strconv.FormatFloat(*durationToMs(hints), 'f', -1, 64))
+	}
+
+	fields = append(fields, "total="+strconv.FormatFloat(*durationToMs(statistics.Total), 'f', -1, 64))
+
+	if statistics.Reused != nil {
+		fields = append(fields, "reused="+strconv.FormatBool(*statistics.Reused))
+	}
+
+	if statistics.ConnWait != nil {
+		fields = append(fields, "conn_wait="+strconv.FormatFloat(*durationToMs(statistics.ConnWait), 'f', -1, 64))
+	}
+
+	if statistics.RemoteAddr != "" {
+		fields = append(fields, fmt.Sprintf("remote_addr=\"%s\"", influxStringReplacer.Replace(statistics.RemoteAddr)))
+	}
+
+	if slowThreshold > 0 {
+		fields = append(fields, "slow="+strconv.FormatBool(statistics.slow(errMsg)))
+	}
+
+	if errMsg != "" {
+		fields = append(fields, fmt.Sprintf("error=\"%s\"", influxStringReplacer.Replace(errMsg)))
+	}
+
+	fmt.Fprintf(out, "%s %s %d\n", tags, strings.Join(fields, ","), statistics.Start.UnixNano())
+}
+
+// printBanner prints a line for every target before the first request, like the PING line of ping.
+// The host is resolved once for it, which does not affect the timing of the requests.
+func printBanner() {
+	protocol := "HTTP/1.1 or HTTP/2"
+
+	switch {
+	case useHttp3:
+		protocol = "HTTP/3"
+	case useH2C:
+		protocol = "HTTP/2 (h2c for http://)"
+	case disableHttp2:
+		protocol = "HTTP/1.1"
+	}
+
+	keepAlive := "keep-alive off"
+
+	if enableKeepAlive {
+		keepAlive = "keep-alive on"
+	}
+
+	details := []string{method, protocol, keepAlive}
+
+	// Neither mode sends requests, so the HTTP options do not apply
+	if dnsOnly {
+		details = []string{"DNS lookup only"}
+	} else if connectOnly {
+		details = []string{"TCP connect only"}
+	}
+
+	details = append(details, fmt.Sprintf("timeout %s", timeout))
+
+	for i, targetUrl := range targetUrls {
+		// Only print a URL given multiple times once
+		if slices.Index(targetUrls, targetUrl) != i {
+			continue
+		}
+
+		if address := bannerAddress(targetUrl); address != "" {
+			fmt.Fprintf(out, "HTTPING %s (%s): %s\n", targetUrl, address, strings.Join(details, ", "))
+		} else {
+			fmt.Fprintf(out, "HTTPING %s: %s\n", targetUrl, strings.Join(details, ", "))
+		}
+	}
+}
+
+// bannerAddress returns the address the requests to the target are sent to, "unresolved" if the lookup failed,
+// or an empty string with --dns-only, which must not resolve the host in advance
+func bannerAddress(targetUrl string) string {
+	if unixSocket != "" {
+		return unixSocket
+	}
+
+	u, err := url.Parse(targetUrl)
+
+	if err != nil {
+		return "unresolved"
+	}
+
+	if resolved, ok := resolve[strings.ToLower(hostPort(u))]; ok {
+		host, _, _ := net.SplitHostPort(resolved)
+		return host
+	}
+
+	// A lookup would warm the cache of the system resolver, so that the first measured lookup is too fast
+	if dnsOnly {
+		return ""
+	}
+
+	ctx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	network := "ip"
+
+	if forceIPv4 {
+		network = "ip4"
+	} else if forceIPv6 {
+		network = "ip6"
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, u.Hostname())
+
+	if err != nil || len(ips) == 0 {
+		return "unresolved"
+	}
+
+	return ips[0].String()
+}
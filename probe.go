@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+)
+
+// probe sends a single request, or only performs its DNS lookup with --dns-only or its TCP connection with --connect-only
+func probe(client *http.Client, ctx context.Context, targetUrl string, index uint64) (*Statistics, error) {
+	if dnsOnly {
+		return lookupHost(ctx, targetUrl, index)
+	}
+
+	if connectOnly {
+		return connect(client, ctx, targetUrl, index)
+	}
+
+	return sendRequest(client, ctx, targetUrl, index)
+}
+
+// lookupHost only resolves the host of the target, for --dns-only.
+// Unlike the DNS phase of a request, the lookup happens every time, as there is no connection that could be reused.
+func lookupHost(ctx context.Context, targetUrl string, index uint64) (*Statistics, error) {
+	startTime := time.Now()
+	statistics := &Statistics{Target: targetUrl, Seq: index, Start: startTime, DNSStart: startTime}
+
+	defer func() {
+		diff := time.Now().Sub(startTime)
+		statistics.Total = &diff
+	}()
+
+	u, err := url.Parse(targetUrl)
+
+	if err != nil {
+		return statistics, err
+	}
+
+	// --dns-timeout is the more specific timeout
+	lookupTimeout := timeout
+
+	if dnsTimeout > 0 {
+		lookupTimeout = dnsTimeout
+	}
+
+	if lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+	}
+
+	// Restrict the address family, so that only A or AAAA records are looked up
+	network := "ip"
+
+	if forceIPv4 {
+		network = "ip4"
+	} else if forceIPv6 {
+		network = "ip6"
+	}
+
+	_, err = net.DefaultResolver.LookupIP(ctx, network, u.Hostname())
+
+	if err != nil {
+		return statistics, err
+	}
+
+	diff := time.Now().Sub(startTime)
+	statistics.DNS = &diff
+
+	return statistics, nil
+}
+
+// connect only opens a TCP connection to the host and port of the target and closes it again, for --connect-only.
+// The connection is dialed like the ones of requests, so that --resolve, --unix-socket, --ipv4 and --ipv6 still apply.
+func connect(client *http.Client, ctx context.Context, targetUrl string, index uint64) (*Statistics, error) {
+	startTime := time.Now()
+	statistics := &Statistics{Target: targetUrl, Seq: index, Start: startTime}
+
+	defer func() {
+		diff := time.Now().Sub(startTime)
+		statistics.Total = &diff
+	}()
+
+	u, err := url.Parse(targetUrl)
+
+	if err != nil {
+		return statistics, err
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			statistics.DNSStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			diff := time.Now().Sub(statistics.DNSStart)
+			statistics.DNS = &diff
+		},
+		ConnectStart: func(network, addr string) {
+			statistics.ConnectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			diff := time.Now().Sub(statistics.ConnectStart)
+			statistics.Connect = &diff
+		},
+	}
+
+	ctx = httptrace.WithClientTrace(ctx, trace)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// --http3 is rejected with --connect-only, so the transport is always an *http.Transport
+	conn, err := client.Transport.(*http.Transport).DialContext(ctx, "tcp", hostPort(u))
+
+	if err != nil {
+		return statistics, err
+	}
+
+	defer conn.Close()
+
+	// Reused is left unset, as every connection is new and there is nothing to reuse
+	statistics.RemoteAddr = conn.RemoteAddr().String()
+	statistics.LocalAddr = conn.LocalAddr().String()
+
+	return statistics, nil
+}
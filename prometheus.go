@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// prometheusQuantiles are the quantiles of httping_request_duration_seconds, matching the percentiles of latencyStats
+var prometheusQuantiles = []string{"0.5", "0.75", "0.9", "0.95", "0.99"}
+
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// pushWarningInterval is the minimum time between warnings about skipped pushes
+const pushWarningInterval = time.Minute
+
+// Periodic pushes to the --pushgateway run in the background, so that a slow Pushgateway does not delay the output.
+// pushInFlight holds a token while a push is running, and the pushes in between are skipped.
+// The other variables are only accessed by the goroutine calling exportPrometheus.
+var (
+	pushInFlight    = make(chan struct{}, 1)
+	pushesSkipped   uint
+	lastPushWarning time.Time
+)
+
+// formatPrometheus returns the statistics of every target in the Prometheus text exposition format
+func formatPrometheus(summaries map[string]*summary) []byte {
+	var buf bytes.Buffer
+
+	// Only include a URL given multiple times once
+	var targets []string
+
+	for i, targetUrl := range targetUrls {
+		if slices.Index(targetUrls, targetUrl) == i {
+			targets = append(targets, targetUrl)
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP httping_requests_total Number of requests that received a response, by status code.")
+	fmt.Fprintln(&buf, "# TYPE httping_requests_total counter")
+
+	for _, target := range targets {
+		for _, status := range sortedKeys(summaries[target].statuses) {
+			// Only the code is used, so that the label does not depend on the reason phrase
+			code, _, _ := strings.Cut(status, " ")
+			fmt.Fprintf(&buf, "httping_requests_total{target=\"%s\",status=\"%s\"} %d\n", escapeLabel(target), escapeLabel(code), summaries[target].statuses[status])
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP httping_request_errors_total Number of failed requests, by reason.")
+	fmt.Fprintln(&buf, "# TYPE httping_request_errors_total counter")
+
+	for _, target := range targets {
+		for _, reason := range sortedKeys(summaries[target].errorReasons) {
+			fmt.Fprintf(&buf, "httping_request_errors_total{target=\"%s\",reason=\"%s\"} %d\n", escapeLabel(target), escapeLabel(reason), summaries[target].errorReasons[reason])
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP httping_request_duration_seconds Total duration of the successful requests.")
+	fmt.Fprintln(&buf, "# TYPE httping_request_duration_seconds summary")
+
+	for _, target := range targets {
+		s := summaries[target].totals.Stats()
+
+		if s.Samples == 0 {
+			continue
+		}
+
+		values := []float64{s.Percentile50, s.Percentile75, s.Percentile90, s.Percentile95, s.Percentile99}
+
+		for i, quantile := range prometheusQuantiles {
+			fmt.Fprintf(&buf, "httping_request_duration_seconds{target=\"%s\",quantile=\"%s\"} %g\n", escapeLabel(target), quantile, values[i]/1000)
+		}
+
+		// The quantiles are calculated from the retained samples, but the sum and count cover every request
+		fmt.Fprintf(&buf, "httping_request_duration_seconds_sum{target=\"%s\"} %g\n", escapeLabel(target), summaries[target].totalsSum/1000)
+		fmt.Fprintf(&buf, "httping_request_duration_seconds_count{target=\"%s\"} %d\n", escapeLabel(target), summaries[target].totalsCount)
+	}
+
+	return buf.Bytes()
+}
+
+func escapeLabel(s string) string {
+	return prometheusLabelReplacer.Replace(s)
+}
+
+// exportPrometheus writes the metrics to the --prometheus file and pushes them to the --pushgateway.
+// Errors are only reported, so that they do not interrupt pinging. The final push waits for a running push and is
+// sent synchronously, so that the final metrics are not overwritten by older ones.
+func exportPrometheus(summaries map[string]*summary, final bool) {
+	metrics := formatPrometheus(summaries)
+
+	if prometheusFile != "" {
+		if err := writeFileAtomic(prometheusFile, metrics); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write Prometheus metrics: %s\n", err)
+		}
+	}
+
+	if pushgateway == "" {
+		return
+	}
+
+	if final {
+		pushInFlight <- struct{}{}
+		pushPrometheus(metrics)
+		return
+	}
+
+	select {
+	case pushInFlight <- struct{}{}:
+		go pushPrometheus(metrics)
+	default:
+		pushesSkipped++
+
+		if time.Since(lastPushWarning) >= pushWarningInterval {
+			fmt.Fprintf(os.Stderr, "Warning: the Pushgateway is too slow, skipped %d push(es)\n", pushesSkipped)
+			pushesSkipped = 0
+			lastPushWarning = time.Now()
+		}
+	}
+}
+
+// pushPrometheus pushes the metrics to the --pushgateway and releases pushInFlight
+func pushPrometheus(metrics []byte) {
+	defer func() { <-pushInFlight }()
+
+	if err := pushMetrics(pushgateway, metrics); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to push Prometheus metrics: %s\n", err)
+	}
+}
+
+// writeFileAtomic writes the file through a temporary file, so that a scraper never reads a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	// Temporary files are only readable by the owner, unlike regular files
+	err = tmp.Chmod(0o644)
+
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// pushMetrics replaces the metrics of the httping job on the Pushgateway
+func pushMetrics(gatewayUrl string, metrics []byte) error {
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(gatewayUrl, "/")+"/metrics/job/httping", bytes.NewReader(metrics))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
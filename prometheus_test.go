@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusCountIsMonotonic(t *testing.T) {
+	const target = "https://example.com/"
+
+	previousMaxSamples, previousTargets := maxSamples, targetUrls
+	maxSamples, targetUrls = 2, []string{target}
+
+	t.Cleanup(func() {
+		maxSamples, targetUrls = previousMaxSamples, previousTargets
+	})
+
+	s := newSummary()
+	summaries := map[string]*summary{target: s}
+
+	for _, total := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond} {
+		s.add(&Statistics{Target: target, Status: "200 OK", Total: &total}, nil)
+	}
+
+	metrics := string(formatPrometheus(summaries))
+
+	// The oldest sample was evicted from the window, but it still counts towards the sum and count
+	for _, expected := range []string{
+		`httping_request_duration_seconds_sum{target="https://example.com/"} 0.6`,
+		`httping_request_duration_seconds_count{target="https://example.com/"} 3`,
+	} {
+		if !strings.Contains(metrics, expected+"\n") {
+			t.Errorf("expected %q in:\n%s", expected, metrics)
+		}
+	}
+}
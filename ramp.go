@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ramp multiplies a value by a factor every number of requests until it reaches a limit, for --delay-ramp and --rate-ramp
+type ramp struct {
+	factor float64
+	every  uint64
+	limit  float64
+}
+
+// parseRamp parses factor,every,limit (e.g. 2,10,5s). The limit is parsed by parseLimit, as it is a delay or a rate,
+// and must lie in the direction of the factor from the initial value.
+func parseRamp(s string, initial float64, parseLimit func(string) (float64, error)) (*ramp, error) {
+	parts := strings.Split(s, ",")
+
+	if len(parts) != 3 {
+		return nil, errors.New("expected factor,every,limit (e.g. 2,10,5s)")
+	}
+
+	factor, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+
+	if err != nil || !(factor > 0) || factor == 1 {
+		return nil, fmt.Errorf("factor must be a positive number other than 1: %q", parts[0])
+	}
+
+	every, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+
+	if err != nil || every == 0 {
+		return nil, fmt.Errorf("every must be a positive integer: %q", parts[1])
+	}
+
+	limit, err := parseLimit(strings.TrimSpace(parts[2]))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid limit %q: %w", parts[2], err)
+	}
+
+	// Otherwise, the value would jump to the limit right away
+	if factor > 1 && limit <= initial {
+		return nil, fmt.Errorf("limit %q must be above the starting value when the factor is greater than 1", parts[2])
+	}
+
+	if factor < 1 && limit >= initial {
+		return nil, fmt.Errorf("limit %q must be below the starting value when the factor is less than 1", parts[2])
+	}
+
+	return &ramp{factor: factor, every: every, limit: limit}, nil
+}
+
+// at returns the value in effect for the request with the given index (starting at 1), warmup requests do not ramp
+func (r *ramp) at(initial float64, index uint64) float64 {
+	steps := (max(index, uint64(warmup)+1) - uint64(warmup) - 1) / r.every
+	value := initial * math.Pow(r.factor, float64(steps))
+
+	// The limit is an upper bound when increasing, and a lower bound when decreasing
+	if r.factor > 1 {
+		return math.Min(value, r.limit)
+	}
+
+	return math.Max(value, r.limit)
+}
+
+// parseDelayLimit parses the limit of --delay-ramp as a duration in nanoseconds
+func parseDelayLimit(s string) (float64, error) {
+	var d millisDuration
+
+	if err := d.Set(s); err != nil {
+		return 0, err
+	}
+
+	return float64(d), nil
+}
+
+// parseRateLimit parses the limit of --rate-ramp in requests per second
+func parseRateLimit(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+
+	if err != nil || !(rate > 0) {
+		return 0, errors.New("expected a positive number of requests per second")
+	}
+
+	return rate, nil
+}
+
+// rampedDelay returns the delay in effect for the request with the given index
+func rampedDelay(index uint64) time.Duration {
+	return time.Duration(delayRamp.at(float64(delay), index))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRamp(t *testing.T) {
+	tests := []struct {
+		spec       string
+		initial    float64
+		parseLimit func(string) (float64, error)
+		expected   ramp
+		err        string
+	}{
+		{"0.5,10,50ms", float64(time.Second), parseDelayLimit, ramp{0.5, 10, float64(50 * time.Millisecond)}, ""},
+		{"2, 5, 3000", float64(time.Second), parseDelayLimit, ramp{2, 5, float64(3 * time.Second)}, ""},
+		{"2,100,500", 10, parseRateLimit, ramp{2, 100, 500}, ""},
+		{"0.5,1,0.1", 10, parseRateLimit, ramp{0.5, 1, 0.1}, ""},
+		{"2,10", 10, parseRateLimit, ramp{}, "expected factor,every,limit (e.g. 2,10,5s)"},
+		{"1,10,500", 10, parseRateLimit, ramp{}, `factor must be a positive number other than 1: "1"`},
+		{"0,10,500", 10, parseRateLimit, ramp{}, `factor must be a positive number other than 1: "0"`},
+		{"x,10,500", 10, parseRateLimit, ramp{}, `factor must be a positive number other than 1: "x"`},
+		{"2,0,500", 10, parseRateLimit, ramp{}, `every must be a positive integer: "0"`},
+		{"2,10,-1", 10, parseRateLimit, ramp{}, `invalid limit "-1": expected a positive number of requests per second`},
+		{"2,10,500ms", float64(time.Second), parseDelayLimit, ramp{}, `limit "500ms" must be above the starting value when the factor is greater than 1`},
+		{"0.5,10,2s", float64(time.Second), parseDelayLimit, ramp{}, `limit "2s" must be below the starting value when the factor is less than 1`},
+		{"2,10,5", 10, parseRateLimit, ramp{}, `limit "5" must be above the starting value when the factor is greater than 1`},
+		{"0.5,10,10", 10, parseRateLimit, ramp{}, `limit "10" must be below the starting value when the factor is less than 1`},
+	}
+
+	for _, test := range tests {
+		r, err := parseRamp(test.spec, test.initial, test.parseLimit)
+
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseRamp(%q) returned %v, expected %q", test.spec, err, test.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseRamp(%q) returned %q", test.spec, err)
+		} else if *r != test.expected {
+			t.Errorf("parseRamp(%q) returned %+v, expected %+v", test.spec, *r, test.expected)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces events to a steady rate without allowing bursts.
+// It is safe for concurrent use, so that it can be shared by all workers.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// SetRate changes the rate of the following events
+func (l *rateLimiter) SetRate(perSecond float64) {
+	l.mu.Lock()
+	l.interval = time.Duration(float64(time.Second) / perSecond)
+	l.mu.Unlock()
+}
+
+// Wait blocks until the next event is allowed to happen, or until the context is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+
+	// Do not catch up on missed events, as that would result in a burst
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// readRawRequest reads a raw HTTP/1.x request (request line, headers and body) from the given file, e.g. one captured
+// with a proxy. Bare line feeds and a body without a Content-Length are accepted as well, so that the file can be
+// written by hand.
+func readRawRequest(path string) (*http.Request, []byte, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	req, err := http.ReadRequest(reader)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Without a Content-Length (or chunked encoding), the body is the rest of the file, as it is in a hand-written request
+	var src io.Reader = req.Body
+
+	if req.Body == http.NoBody {
+		src = reader
+	}
+
+	// The body is read once, since the request is rebuilt on every iteration
+	body, err := io.ReadAll(src)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Without a body, no Content-Length should be sent either
+	if len(body) == 0 {
+		body = nil
+	}
+
+	return req, body, nil
+}
+
+// withRequestURI replaces the path and query of the target with the ones of the raw request.
+// The scheme and host of the target still determine where the request is sent to.
+func withRequestURI(targetUrl string, uri *url.URL) string {
+	u, err := url.Parse(targetUrl)
+
+	if err != nil {
+		return targetUrl
+	}
+
+	u.Path = uri.Path
+	u.RawPath = uri.RawPath
+	u.RawQuery = uri.RawQuery
+	return u.String()
+}
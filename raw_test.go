@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadRawRequestBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		body    string
+	}{
+		{"without body", "GET /health HTTP/1.1\r\nHost: example.com\r\n\r\n", ""},
+		{"content length", "POST /items HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhello, ignored", "hello"},
+		{"chunked", "POST /items HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", "hello"},
+		{"without content length", "POST /items HTTP/1.1\nHost: example.com\n\n{\"name\": \"x\"}\n", "{\"name\": \"x\"}\n"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "request.txt")
+
+		if err := os.WriteFile(path, []byte(test.request), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, body, err := readRawRequest(path)
+
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if string(body) != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.name, test.body, body)
+		}
+	}
+}
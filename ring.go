@@ -0,0 +1,45 @@
+package main
+
+// ring stores samples up to a fixed capacity, after which the oldest sample is dropped for every new one.
+// A capacity of 0 means that the amount of samples is unlimited.
+type ring struct {
+	samples  []float64
+	capacity int
+	next     int
+	dropped  uint
+}
+
+func newRing(capacity int) *ring {
+	return &ring{capacity: capacity}
+}
+
+func (r *ring) Add(sample float64) {
+	if r.capacity == 0 || len(r.samples) < r.capacity {
+		r.samples = append(r.samples, sample)
+		return
+	}
+
+	// Overwrite the oldest sample
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % r.capacity
+	r.dropped++
+}
+
+// Values returns all retained samples, oldest first
+func (r *ring) Values() []float64 {
+	if r.next == 0 {
+		return r.samples
+	}
+	return append(r.samples[r.next:len(r.samples):len(r.samples)], r.samples[:r.next]...)
+}
+
+// Dropped returns the amount of samples that were dropped because the ring was full
+func (r *ring) Dropped() uint {
+	return r.dropped
+}
+
+func (r *ring) Stats() *latencyStats {
+	s := calculateStats(r.Values())
+	s.Dropped = r.dropped
+	return s
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"github.com/montanaflynn/stats"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sampleStore stores latency samples and calculates statistics from them
+type sampleStore interface {
+	Add(sample float64)
+	Stats() *latencyStats
+}
+
+// latencyStats contains the statistics calculated from latency samples, in milliseconds.
+// All fields except Samples and Dropped are 0 if there are no samples.
+type latencyStats struct {
+	Samples           int
+	Dropped           uint
+	Min               float64
+	Max               float64
+	Average           float64
+	GeometricMean     float64
+	StandardDeviation float64
+	Jitter            float64
+	Percentile99      float64
+	Percentile95      float64
+	Percentile90      float64
+	Percentile75      float64
+	Percentile50      float64
+
+	// Percentiles contains the percentiles given by --percentiles, in the same order
+	Percentiles []float64
+}
+
+func newSampleStore() sampleStore {
+	if streamStats {
+		return newStreamStore()
+	}
+	return newRing(int(maxSamples))
+}
+
+// calculateStats calculates the exact statistics of the given samples
+func calculateStats(samples []float64) *latencyStats {
+	s := &latencyStats{Samples: len(samples)}
+
+	if len(samples) == 0 {
+		return s
+	}
+
+	s.Min, _ = stats.Min(samples)
+	s.Max, _ = stats.Max(samples)
+	s.Average, _ = stats.Mean(samples)
+	s.GeometricMean = geometricMean(samples)
+	s.StandardDeviation, _ = stats.StandardDeviation(samples)
+	s.Jitter = jitter(samples)
+
+	s.Percentile99, _ = stats.Percentile(samples, 99)
+	s.Percentile95, _ = stats.Percentile(samples, 95)
+	s.Percentile90, _ = stats.Percentile(samples, 90)
+	s.Percentile75, _ = stats.Percentile(samples, 75)
+	s.Percentile50, _ = stats.Percentile(samples, 50)
+
+	s.Percentiles = make([]float64, len(percentiles))
+
+	for i, p := range percentiles {
+		s.Percentiles[i] = percentile(samples, p)
+	}
+
+	return s
+}
+
+// percentile returns the percentile of the samples.
+// stats.Percentile rejects percentiles below the first sample (e.g. the 1st of 10 samples), which are the minimum instead.
+func percentile(samples []float64, p float64) float64 {
+	value, err := stats.Percentile(samples, p)
+
+	if err != nil {
+		value, _ = stats.Min(samples)
+	}
+
+	return value
+}
+
+// parsePercentiles parses a comma-separated list of percentiles, e.g. 50,90,99,99.9
+func parsePercentiles(s string) ([]float64, error) {
+	var result []float64
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		p, err := strconv.ParseFloat(part, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile: %q", part)
+		}
+
+		if !(p > 0 && p <= 100) {
+			return nil, fmt.Errorf("percentile must be greater than 0 and at most 100: %s", part)
+		}
+
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// formatPercentile returns the name of a percentile, e.g. 99th or 99.9th
+func formatPercentile(p float64) string {
+	name := strconv.FormatFloat(p, 'f', -1, 64)
+
+	// 1st, 2nd and 3rd, but 11th, 12th and 13th
+	if p == math.Trunc(p) && int(p)%100/10 != 1 {
+		switch int(p) % 10 {
+		case 1:
+			return name + "st"
+		case 2:
+			return name + "nd"
+		case 3:
+			return name + "rd"
+		}
+	}
+
+	return name + "th"
+}
+
+// geometricMean returns the geometric mean of the positive samples, or 0 if there are none.
+// stats.GeometricMean multiplies all samples first, which overflows after a few hundred samples, so the logarithms are averaged instead.
+// Samples of 0 (below the clock resolution) are skipped, as they would make the geometric mean 0.
+func geometricMean(samples []float64) float64 {
+	var sum float64
+	var n int
+
+	for _, sample := range samples {
+		if sample > 0 {
+			sum += math.Log(sample)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	return math.Exp(sum / float64(n))
+}
+
+// jitter returns the mean absolute difference between consecutive latencies, or 0 if there are less than two
+func jitter(latencies []float64) float64 {
+	if len(latencies) < 2 {
+		return 0
+	}
+
+	var sum float64
+
+	for i := 1; i < len(latencies); i++ {
+		sum += math.Abs(latencies[i] - latencies[i-1])
+	}
+
+	return sum / float64(len(latencies)-1)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// summarySignals print the current statistics to stderr without stopping
+var summarySignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// summarySignals is empty, as Windows has no SIGHUP or SIGUSR1 to send to a process
+var summarySignals []os.Signal
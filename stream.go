@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// streamStore calculates approximate statistics without storing the samples, using constant memory.
+// The percentiles are estimated with the P² algorithm, all other statistics are exact.
+type streamStore struct {
+	count    int
+	min      float64
+	max      float64
+	mean     float64
+	m2       float64 // Sum of squared differences from the mean (Welford's algorithm)
+	previous float64
+	jitter   float64 // Sum of absolute differences between consecutive samples
+	logSum   float64 // Sum of the logarithms of the positive samples, for the geometric mean
+	logCount int
+
+	percentile99 *p2Quantile
+	percentile95 *p2Quantile
+	percentile90 *p2Quantile
+	percentile75 *p2Quantile
+	percentile50 *p2Quantile
+
+	// One estimator for every percentile given by --percentiles
+	percentiles []*p2Quantile
+}
+
+func newStreamStore() *streamStore {
+	s := &streamStore{
+		percentile99: newP2Quantile(0.99),
+		percentile95: newP2Quantile(0.95),
+		percentile90: newP2Quantile(0.90),
+		percentile75: newP2Quantile(0.75),
+		percentile50: newP2Quantile(0.50),
+		percentiles:  make([]*p2Quantile, len(percentiles)),
+	}
+
+	for i, p := range percentiles {
+		s.percentiles[i] = newP2Quantile(p / 100)
+	}
+
+	return s
+}
+
+func (s *streamStore) Add(sample float64) {
+	s.count++
+
+	if s.count == 1 {
+		s.min, s.max = sample, sample
+	} else {
+		s.min = min(s.min, sample)
+		s.max = max(s.max, sample)
+		s.jitter += math.Abs(sample - s.previous)
+	}
+
+	s.previous = sample
+
+	delta := sample - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (sample - s.mean)
+
+	if sample > 0 {
+		s.logSum += math.Log(sample)
+		s.logCount++
+	}
+
+	s.percentile99.Add(sample)
+	s.percentile95.Add(sample)
+	s.percentile90.Add(sample)
+	s.percentile75.Add(sample)
+	s.percentile50.Add(sample)
+
+	for _, q := range s.percentiles {
+		q.Add(sample)
+	}
+}
+
+func (s *streamStore) Stats() *latencyStats {
+	result := &latencyStats{Samples: s.count}
+
+	if s.count == 0 {
+		return result
+	}
+
+	result.Min = s.min
+	result.Max = s.max
+	result.Average = s.mean
+	if s.logCount > 0 {
+		result.GeometricMean = math.Exp(s.logSum / float64(s.logCount))
+	}
+
+	result.StandardDeviation = math.Sqrt(s.m2 / float64(s.count))
+
+	if s.count > 1 {
+		result.Jitter = s.jitter / float64(s.count-1)
+	}
+
+	result.Percentile99 = s.percentile99.Value()
+	result.Percentile95 = s.percentile95.Value()
+	result.Percentile90 = s.percentile90.Value()
+	result.Percentile75 = s.percentile75.Value()
+	result.Percentile50 = s.percentile50.Value()
+	result.Percentiles = make([]float64, len(s.percentiles))
+
+	for i, q := range s.percentiles {
+		result.Percentiles[i] = q.Value()
+	}
+
+	return result
+}
+
+// p2Quantile estimates a single quantile using the P² algorithm by Jain and Chlamtac,
+// which only keeps track of five markers instead of all samples.
+type p2Quantile struct {
+	p         float64
+	count     int
+	heights   [5]float64
+	positions [5]float64
+	desired   [5]float64
+	increment [5]float64
+}
+
+func newP2Quantile(p float64) *p2Quantile {
+	return &p2Quantile{
+		p:         p,
+		desired:   [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		increment: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+func (q *p2Quantile) Add(sample float64) {
+	// The first five samples are used as the initial markers
+	if q.count < 5 {
+		q.heights[q.count] = sample
+		q.count++
+
+		if q.count == 5 {
+			slices.Sort(q.heights[:])
+			q.positions = [5]float64{1, 2, 3, 4, 5}
+		}
+
+		return
+	}
+
+	q.count++
+
+	// Find the cell the sample falls into, adjusting the extreme markers if needed
+	var k int
+
+	switch {
+	case sample < q.heights[0]:
+		q.heights[0] = sample
+		k = 0
+	case sample >= q.heights[4]:
+		q.heights[4] = sample
+		k = 3
+	default:
+		for k = 0; k < 3; k++ {
+			if sample < q.heights[k+1] {
+				break
+			}
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		q.positions[i]++
+	}
+
+	for i := range q.desired {
+		q.desired[i] += q.increment[i]
+	}
+
+	// Adjust the heights of the middle markers if they are off from their desired positions
+	for i := 1; i < 4; i++ {
+		d := q.desired[i] - q.positions[i]
+
+		if (d >= 1 && q.positions[i+1]-q.positions[i] > 1) || (d <= -1 && q.positions[i-1]-q.positions[i] < -1) {
+			sign := math.Copysign(1, d)
+			height := q.parabolic(i, sign)
+
+			if q.heights[i-1] < height && height < q.heights[i+1] {
+				q.heights[i] = height
+			} else {
+				q.heights[i] = q.linear(i, sign)
+			}
+
+			q.positions[i] += sign
+		}
+	}
+}
+
+func (q *p2Quantile) parabolic(i int, d float64) float64 {
+	return q.heights[i] + d/(q.positions[i+1]-q.positions[i-1])*
+		((q.positions[i]-q.positions[i-1]+d)*(q.heights[i+1]-q.heights[i])/(q.positions[i+1]-q.positions[i])+
+			(q.positions[i+1]-q.positions[i]-d)*(q.heights[i]-q.heights[i-1])/(q.positions[i]-q.positions[i-1]))
+}
+
+func (q *p2Quantile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return q.heights[i] + d*(q.heights[j]-q.heights[i])/(q.positions[j]-q.positions[i])
+}
+
+// Value returns the estimated quantile, which is exact for fewer than five samples
+func (q *p2Quantile) Value() float64 {
+	if q.count < 5 {
+		return percentile(q.heights[:q.count], q.p*100)
+	}
+	return q.heights[2]
+}
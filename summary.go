@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/montanaflynn/stats"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// summary aggregates the results of all requests to a single target
+type summary struct {
+	requests   uint
+	successful uint
+	failed     uint
+
+	// Number of responses for every status (e.g. "200 OK"), including the ones that did not meet the expectations
+	statuses map[string]uint
+
+	// Number of failed requests for every error reason, see classifyError
+	errorReasons map[string]uint
+
+	// Number of successful requests that took longer than --slow-threshold
+	slow uint
+
+	// Number of consecutive failed requests up to the last request, and the highest number seen, like packet loss in ping.
+	// Scattered single failures indicate a flaky target, long streaks an outage.
+	currentStreak uint
+	longestStreak uint
+
+	// Successful requests for which connection reuse was observed, and how many of them reused a connection
+	reuseObserved uint
+	reused        uint
+
+	// Total latency of every request
+	totals sampleStore
+
+	// Number and sum in milliseconds of all total latencies, which unlike totals never evict old samples,
+	// so that the Prometheus _count and _sum only ever increase
+	totalsCount uint64
+	totalsSum   float64
+
+	// TTFB of every request, as it excludes the download and is the best indication of how responsive the server is
+	ttfbs sampleStore
+
+	// Sum of the download throughput in MB/s of every request that downloaded a body, used for the average
+	throughputSum   float64
+	throughputCount uint
+
+	// Bytes downloaded by all successful requests and the time spent downloading them, for the overall throughput
+	totalBytes   int64
+	downloadTime time.Duration
+
+	// Latency of every request for each phase, only used with --phase-stats
+	phaseLatencies []sampleStore
+}
+
+func newSummary() *summary {
+	s := &summary{
+		statuses:       map[string]uint{},
+		errorReasons:   map[string]uint{},
+		totals:         newSampleStore(),
+		ttfbs:          newSampleStore(),
+		phaseLatencies: make([]sampleStore, len(phaseNames)),
+	}
+
+	for i := range s.phaseLatencies {
+		s.phaseLatencies[i] = newSampleStore()
+	}
+
+	return s
+}
+
+// add records the result of a single request
+func (s *summary) add(statistics *Statistics, err error) {
+	s.requests++
+
+	if statistics.Status != "" {
+		s.statuses[statistics.Status]++
+	}
+
+	if err != nil {
+		s.failed++
+		s.errorReasons[classifyError(err)]++
+		s.currentStreak++
+		s.longestStreak = max(s.longestStreak, s.currentStreak)
+		return
+	}
+
+	s.successful++
+	s.currentStreak = 0
+
+	if statistics.slow("") {
+		s.slow++
+	}
+
+	if statistics.Bytes != nil && statistics.Download != nil {
+		s.totalBytes += *statistics.Bytes
+		s.downloadTime += *statistics.Download
+	}
+
+	if statistics.Reused != nil {
+		s.reuseObserved++
+
+		if *statistics.Reused {
+			s.reused++
+		}
+	}
+
+	// If noNewConnCount is enabled, only count the request if the connection was reused
+	if noNewConnCount && !*statistics.Reused {
+		return
+	}
+
+	total := float64(*statistics.Total) / float64(time.Millisecond)
+	s.totals.Add(total)
+	s.totalsCount++
+	s.totalsSum += total
+
+	if statistics.TTFB != nil {
+		s.ttfbs.Add(float64(*statistics.TTFB) / float64(time.Millisecond))
+	}
+
+	// Empty bodies have no meaningful throughput
+	if mbps := statistics.throughput(); mbps != nil {
+		s.throughputSum += *mbps
+		s.throughputCount++
+	}
+
+	if phaseStats {
+		// Phases that did not happen (e.g. TLS for plain HTTP) are skipped
+		for i, phase := range statistics.phases() {
+			if phase != nil {
+				s.phaseLatencies[i].Add(float64(*phase) / float64(time.Millisecond))
+			}
+		}
+	}
+}
+
+// printReport prints a compact line with the statistics of the last report interval
+func printReport(requests, failed uint, totals []float64) {
+	average, err := stats.Mean(totals)
+	percentile95, _ := stats.Percentile(totals, 95)
+
+	switch output {
+	case outputCSV, outputInflux:
+		// Reports would not fit into the CSV columns or the measurement
+	case outputJSON:
+		report := &jsonReport{
+			Type:     "report",
+			Interval: reportInterval.Seconds(),
+			Requests: requests,
+			Failed:   failed,
+		}
+
+		if err == nil {
+			report.Average = &average
+			report.Percentile95 = &percentile95
+		}
+
+		printJSON(report)
+	default:
+		avg, p95 := "N/A", "N/A"
+
+		if err == nil {
+			avg = fmt.Sprintf("%.1fms", average)
+			p95 = fmt.Sprintf("%.1fms", percentile95)
+		}
+
+		fmt.Fprintf(out, "--- last %s: requests=%d failed=%d avg=%s p95=%s\n", reportInterval, requests, failed, avg, p95)
+	}
+}
+
+// printSummaries prints the statistics of every target
+func printSummaries(summaries map[string]*summary) {
+	for i, targetUrl := range targetUrls {
+		// Only print the statistics of a URL given multiple times once
+		if slices.Index(targetUrls, targetUrl) != i {
+			continue
+		}
+
+		printSummary(targetUrl, summaries[targetUrl])
+	}
+}
+
+// printSummary prints the final statistics of a single target.
+// If samples were dropped because of --max-samples, the statistics only reflect the retained samples.
+func printSummary(target string, summary *summary) {
+	requests, successful, failed := summary.requests, summary.successful, summary.failed
+	totals, phaseLatencies := summary.totals, summary.phaseLatencies
+	s := totals.Stats()
+	ttfb := summary.ttfbs.Stats()
+
+	// CSV and InfluxDB output only contain the requests, so that they can be imported as-is
+	if output == outputCSV || output == outputInflux {
+		return
+	}
+
+	if output == outputPing {
+		printPingSummary(target, summary, s)
+		return
+	}
+
+	if output == outputJSON {
+		result := &jsonSummary{
+			Type:       "summary",
+			Target:     target,
+			Requests:   requests,
+			Successful: successful,
+			Failed:     failed,
+			Samples:    s.Samples,
+			Statuses:   summary.statuses,
+			Errors:     summary.errorReasons,
+
+			LongestFailureStreak: summary.longestStreak,
+			CurrentFailureStreak: summary.currentStreak,
+		}
+
+		if summary.reuseObserved > 0 {
+			reuseRate := float64(summary.reused) / float64(summary.reuseObserved) * 100
+			result.ReuseRate = &reuseRate
+		}
+
+		if s.Samples > 0 {
+			result.Min = &s.Min
+			result.Max = &s.Max
+			result.Average = &s.Average
+			result.GeometricMean = &s.GeometricMean
+			result.StandardDeviation = &s.StandardDeviation
+			result.Jitter = &s.Jitter
+			result.Percentile99 = &s.Percentile99
+			result.Percentile95 = &s.Percentile95
+			result.Percentile90 = &s.Percentile90
+			result.Percentile75 = &s.Percentile75
+			result.Percentile50 = &s.Percentile50
+			result.Percentiles = map[string]float64{}
+
+			for i, p := range percentiles {
+				result.Percentiles[strconv.FormatFloat(p, 'f', -1, 64)] = s.Percentiles[i]
+			}
+		}
+
+		if ttfb.Samples > 0 {
+			result.TTFBPercentile99 = &ttfb.Percentile99
+			result.TTFBPercentile95 = &ttfb.Percentile95
+			result.TTFBPercentile50 = &ttfb.Percentile50
+		}
+
+		if summary.throughputCount > 0 {
+			throughput := summary.throughputSum / float64(summary.throughputCount)
+			result.Throughput = &throughput
+		}
+
+		if slowThreshold > 0 {
+			result.Slow = &summary.slow
+		}
+
+		result.TotalBytes = summary.totalBytes
+		result.OverallThroughput = summary.overallThroughput()
+
+		if phaseStats {
+			result.Phases = map[string]*jsonPhase{}
+
+			for i, phase := range phaseLatencies {
+				if ps := phase.Stats(); ps.Samples > 0 {
+					result.Phases[phaseNames[i]] = &jsonPhase{Min: ps.Min, Average: ps.Average, Percentile95: ps.Percentile95}
+				}
+			}
+		}
+
+		printJSON(result)
+		return
+	}
+
+	fmt.Fprintln(out)
+
+	// Label the statistics if there are multiple targets
+	if len(targetUrls) > 1 {
+		fmt.Fprintf(out, "Target: %s\n", target)
+	}
+
+	fmt.Fprintf(out, "Requests: %d (%d successful, %d failed)\n", requests, successful, failed)
+
+	if slowThreshold > 0 && successful > 0 {
+		fmt.Fprintf(out, "Slow: %d of %d successful requests (%.1f%%) took longer than %s\n", summary.slow, successful, float64(summary.slow)/float64(successful)*100, slowThreshold)
+	}
+
+	if summary.reuseObserved > 0 {
+		fmt.Fprintf(out, "Connection Reuse: %.1f%% (%d of %d)\n", float64(summary.reused)/float64(summary.reuseObserved)*100, summary.reused, summary.reuseObserved)
+	}
+
+	// Also printed for a single status, so that e.g. a run that only got 503 shows it
+	if len(summary.statuses) > 0 {
+		statuses := sortedKeys(summary.statuses)
+
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "%-30s %-7s %s\n", "Status", "Count", "Percentage")
+
+		for _, status := range statuses {
+			n := summary.statuses[status]
+			fmt.Fprintf(out, "%-30s %-7d %.1f%%\n", status, n, float64(n)/float64(requests)*100)
+		}
+	}
+
+	if len(summary.errorReasons) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "%-30s %-7s %s\n", "Error", "Count", "Percentage")
+
+		// Same order as classifyError, so that network errors come first
+		for _, reason := range errorReasons {
+			if n, ok := summary.errorReasons[reason]; ok {
+				fmt.Fprintf(out, "%-30s %-7d %.1f%%\n", reason, n, float64(n)/float64(requests)*100)
+			}
+		}
+
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Longest Failure Streak: %d\n", summary.longestStreak)
+		fmt.Fprintf(out, "Current Failure Streak: %d\n", summary.currentStreak)
+	}
+
+	if summary.totalBytes > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Total Downloaded: %s\n", formatBytes(summary.totalBytes))
+
+		if mbps := summary.overallThroughput(); mbps != nil {
+			fmt.Fprintf(out, "Overall Throughput: %.1fMB/s\n", *mbps)
+		}
+	}
+
+	if s.Dropped > 0 {
+		fmt.Fprintf(out, "Statistics are based on the last %d samples (see --max-samples)\n", s.Samples)
+	}
+
+	if s.Samples > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "Min: %.1fms\n", s.Min)
+		fmt.Fprintf(out, "Max: %.1fms\n", s.Max)
+		fmt.Fprintf(out, "Average: %.1fms\n", s.Average)
+		fmt.Fprintf(out, "Geometric Mean: %.1fms\n", s.GeometricMean)
+		fmt.Fprintf(out, "Standard Deviation: %.1fms\n", s.StandardDeviation)
+		fmt.Fprintf(out, "Jitter: %.1fms\n", s.Jitter)
+
+		if summary.throughputCount > 0 {
+			fmt.Fprintf(out, "Average Throughput: %.1fMB/s\n", summary.throughputSum/float64(summary.throughputCount))
+		}
+
+		if len(percentiles) > 0 {
+			fmt.Fprintln(out)
+
+			for i, p := range percentiles {
+				fmt.Fprintf(out, "%s Percentile: %.1fms\n", formatPercentile(p), s.Percentiles[i])
+			}
+		}
+
+		if ttfb.Samples > 0 {
+			fmt.Fprintln(out)
+			fmt.Fprintf(out, "TTFB 99th Percentile: %.1fms\n", ttfb.Percentile99)
+			fmt.Fprintf(out, "TTFB 95th Percentile: %.1fms\n", ttfb.Percentile95)
+			fmt.Fprintf(out, "TTFB 50th Percentile: %.1fms\n", ttfb.Percentile50)
+		}
+
+		// Histograms require every sample, which is only the case with exact statistics
+		if r, ok := totals.(*ring); ok && histogram {
+			fmt.Fprintln(out)
+			printHistogram(r.Values(), int(histogramBins))
+		}
+	}
+
+	phaseStatistics := make([]*latencyStats, len(phaseLatencies))
+
+	for i, phase := range phaseLatencies {
+		phaseStatistics[i] = phase.Stats()
+	}
+
+	if phaseStats && slices.ContainsFunc(phaseStatistics, func(ps *latencyStats) bool { return ps.Samples > 0 }) {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "%-6s %-9s %-9s %s\n", "Phase", "Min", "Average", "95th")
+
+		for i, ps := range phaseStatistics {
+			// The phase was never observed (e.g. TLS for plain HTTP)
+			if ps.Samples == 0 {
+				continue
+			}
+
+			fmt.Fprintf(out, "%-6s %-9s %-9s %s\n", phaseNames[i],
+				fmt.Sprintf("%.1fms", ps.Min),
+				fmt.Sprintf("%.1fms", ps.Average),
+				fmt.Sprintf("%.1fms", ps.Percentile95),
+			)
+		}
+	}
+}
+
+// printPingSummary prints the final statistics of a single target like ping does
+func printPingSummary(target string, summary *summary, s *latencyStats) {
+	name := target
+
+	if u, err := url.Parse(target); err == nil {
+		name = u.Host
+	}
+
+	var loss float64
+
+	if summary.requests > 0 {
+		loss = float64(summary.failed) / float64(summary.requests) * 100
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "--- %s ping statistics ---\n", name)
+	fmt.Fprintf(out, "%d requests transmitted, %d successful, %.1f%% failed\n", summary.requests, summary.successful, loss)
+
+	// mdev is the standard deviation, like in the ping of iputils
+	if s.Samples > 0 {
+		fmt.Fprintf(out, "rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n", s.Min, s.Average, s.Max, s.StandardDeviation)
+	}
+}
+
+// overallThroughput returns the total bytes divided by the total download time in MB/s, or nil if nothing was downloaded
+func (s *summary) overallThroughput() *float64 {
+	if s.totalBytes == 0 || s.downloadTime <= 0 {
+		return nil
+	}
+
+	mbps := float64(s.totalBytes) / 1e6 / s.downloadTime.Seconds()
+	return &mbps
+}
+
+// formatBytes returns the amount of bytes in a human-readable unit, e.g. 1.5MB (using powers of 1000 like the throughput)
+func formatBytes(n int64) string {
+	units := []string{"KB", "MB", "GB", "TB"}
+
+	if n < 1000 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value := float64(n) / 1000
+	i := 0
+
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
+
+// sortedKeys returns the keys of the map in ascending order
+func sortedKeys(m map[string]uint) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
+
+// errorReasons contains every reason returned by classifyError
+var errorReasons = []string{"dns timeout", "dns", "connection refused", "connection reset", "tls", "timeout", "other", "unexpected response"}
+
+// classifyError returns the reason a request failed, to tell network errors apart from server-side ones
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+	var expectationErr *expectationError
+
+	switch {
+	case errors.As(err, &expectationErr):
+		return "unexpected response"
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+		return "dns timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.As(err, &certErr), errors.As(err, &alertErr), errors.As(err, &recordErr),
+		errors.As(err, &hostnameErr), errors.As(err, &authorityErr), errors.As(err, &invalidErr):
+		return "tls"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readUrlFile reads the URLs from the given file, one per line, optionally followed by a weight (e.g. "https://example.com 3").
+// Blank lines and lines starting with # are ignored. URLs without a weight have a weight of 1.
+// Environment variables in the URLs are expanded like the URLs on the command line.
+// All malformed lines are reported at once, so that they can be fixed before starting.
+func readUrlFile(path string) ([]string, []uint, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	var urls []string
+	var weights []uint
+	var errs []error
+
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		weight := uint64(1)
+
+		if len(fields) > 2 {
+			errs = append(errs, fmt.Errorf("line %d: expected a URL and an optional weight", lineNumber))
+			continue
+		}
+
+		if len(fields) == 2 {
+			weight, err = strconv.ParseUint(fields[1], 10, 32)
+
+			if err != nil || weight == 0 {
+				errs = append(errs, fmt.Errorf("line %d: invalid weight: %s", lineNumber, fields[1]))
+				continue
+			}
+		}
+
+		expanded, err := expandEnv(fields[0])
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+
+		targetUrl := withDefaultScheme(expanded)
+
+		if err := validateUrl(targetUrl); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+
+		urls = append(urls, targetUrl)
+		weights = append(weights, uint(weight))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return urls, weights, errors.Join(errs...)
+}
+
+// parseWeights parses a comma-separated list of weights, e.g. 3,1
+func parseWeights(s string) ([]uint, error) {
+	var weights []uint
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		weight, err := strconv.ParseUint(part, 10, 32)
+
+		if err != nil || weight == 0 {
+			return nil, fmt.Errorf("weight must be a positive integer: %q", part)
+		}
+
+		weights = append(weights, uint(weight))
+	}
+
+	return weights, nil
+}
+
+// appendCacheBust appends the --cache-bust-param with the current time in nanoseconds to the raw query.
+// The existing parameters are kept as they are, rather than being decoded and encoded again.
+func appendCacheBust(rawQuery string) string {
+	if rawQuery != "" {
+		rawQuery += "&"
+	}
+
+	return rawQuery + url.QueryEscape(cacheBustParam) + "=" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// weightedSchedule returns the order in which the targets are pinged, in which every target occurs as often as its weight.
+// It uses smooth weighted round-robin (as nginx does), so that the requests to a target are spread evenly instead of sent in bursts,
+// e.g. the weights 3 and 1 result in a, a, b, a rather than a, a, a, b.
+func weightedSchedule(targets []string, weights []uint) []string {
+	var total int
+	current := make([]int, len(targets))
+
+	for _, weight := range weights {
+		total += int(weight)
+	}
+
+	schedule := make([]string, 0, total)
+
+	for range total {
+		best := 0
+
+		for i, weight := range weights {
+			current[i] += int(weight)
+
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+
+		current[best] -= total
+		schedule = append(schedule, targets[best])
+	}
+
+	return schedule
+}
+
+// schemeRegexp matches a scheme at the start of a URL, but not a :// in its path or query
+var schemeRegexp = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9+.-]*://")
+
+// withDefaultScheme prepends https:// to a URL without a scheme, e.g. example.com or localhost:8080
+func withDefaultScheme(s string) string {
+	if !schemeRegexp.MatchString(s) {
+		return "https://" + s
+	}
+
+	return s
+}
+
+// hostPort returns the host and port of the URL, the scheme determines the default port
+func hostPort(u *url.URL) string {
+	port := u.Port()
+
+	if port == "" {
+		port = "80"
+
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// validateUrl checks whether the given URL can be pinged
+func validateUrl(s string) error {
+	u, err := url.Parse(s)
+
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s: unsupported scheme %q, expected http or https", s, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%s: missing host", s)
+	}
+
+	return nil
+}
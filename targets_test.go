@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateUrl(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"https://example.com", ""},
+		{"http://localhost:8080/path?query=1", ""},
+		{"http://[::1]:8080", ""},
+		{"http://[::1", `parse "http://[::1": missing ']' in host`},
+		{"://x", `parse "://x": missing protocol scheme`},
+		{"ftp://x", `ftp://x: unsupported scheme "ftp", expected http or https`},
+		{"example.com", `example.com: unsupported scheme "", expected http or https`},
+		{"http://", "http://: missing host"},
+		{"https:///path", "https:///path: missing host"},
+	}
+
+	for _, test := range tests {
+		err := validateUrl(test.url)
+
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("validateUrl(%q) returned %q, expected no error", test.url, err)
+			}
+
+			continue
+		}
+
+		if err == nil || err.Error() != test.err {
+			t.Errorf("validateUrl(%q) returned %v, expected %q", test.url, err, test.err)
+		}
+	}
+}
+
+func TestWithDefaultScheme(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"example.com", "https://example.com"},
+		{"localhost:8080", "https://localhost:8080"},
+		{"example.com/?next=http://x", "https://example.com/?next=http://x"},
+		{"ftp://x", "ftp://x"},
+		{"http://example.com", "http://example.com"},
+		{"https://example.com", "https://example.com"},
+	}
+
+	for _, test := range tests {
+		if actual := withDefaultScheme(test.url); actual != test.expected {
+			t.Errorf("withDefaultScheme(%q) returned %q, expected %q", test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestReadUrlFileExpandsEnv(t *testing.T) {
+	t.Setenv("HTTPING_TEST_HOST", "example.com")
+
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	content := "# Comment\nhttps://${HTTPING_TEST_HOST}/health 3\n${HTTPING_TEST_HOST}/$${literal}\n"
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, weights, err := readUrlFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://example.com/health", "https://example.com/${literal}"}
+
+	if !slices.Equal(urls, expected) {
+		t.Errorf("expected URLs %q, got %q", expected, urls)
+	}
+
+	if !slices.Equal(weights, []uint{3, 1}) {
+		t.Errorf("expected weights [3 1], got %v", weights)
+	}
+}
+
+func TestReadUrlFileUnsetVariable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+
+	if err := os.WriteFile(path, []byte("https://${HTTPING_TEST_UNSET}/\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := readUrlFile(path)
+
+	if expected := "line 1: environment variable not set: HTTPING_TEST_UNSET"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
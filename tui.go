@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// dashboardGraphWidth is the number of requests shown in the latency graph
+	dashboardGraphWidth = 60
+
+	// dashboardWindow is the number of requests the rolling percentiles are calculated from
+	dashboardWindow = 100
+
+	// dashboardLines is the number of recent requests listed
+	dashboardLines = 10
+
+	// dashboardRefresh limits how often the dashboard is redrawn, so that fast requests (e.g. --flood) do not flicker
+	dashboardRefresh = 100 * time.Millisecond
+)
+
+// sparkBlocks are the characters of the latency graph, from low to high
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// dashboard is the --tui mode, which redraws a live overview of all targets instead of printing a line per request.
+// It only uses ANSI escape codes and draws on the alternate screen, so that the terminal is restored afterwards.
+type dashboard struct {
+	w        io.Writer
+	started  time.Time
+	drawn    time.Time
+	requests uint
+	failed   uint
+
+	// Total latency in milliseconds of the most recent requests, oldest first. Failed requests are nil.
+	latencies []*float64
+
+	// Most recent requests in the format of --ping-style, oldest first
+	lines []string
+}
+
+func newDashboard(w io.Writer) *dashboard {
+	// Switch to the alternate screen and hide the cursor
+	fmt.Fprint(w, "\u001B[?1049h\u001B[?25l")
+	return &dashboard{w: w, started: time.Now()}
+}
+
+// Close restores the screen and cursor
+func (d *dashboard) Close() {
+	fmt.Fprint(d.w, "\u001B[?25h\u001B[?1049l")
+}
+
+// Add records a request and redraws the dashboard, unless it was drawn very recently
+func (d *dashboard) Add(statistics *Statistics, errMsg string) {
+	if !statistics.Warmup {
+		d.requests++
+
+		var latency *float64
+
+		if errMsg != "" {
+			d.failed++
+		} else {
+			latency = durationToMs(statistics.Total)
+		}
+
+		d.latencies = append(d.latencies, latency)
+
+		if len(d.latencies) > dashboardWindow {
+			d.latencies = d.latencies[1:]
+		}
+	}
+
+	d.lines = append(d.lines, formatPingResult(statistics, errMsg))
+
+	if len(d.lines) > dashboardLines {
+		d.lines = d.lines[1:]
+	}
+
+	if time.Since(d.drawn) >= dashboardRefresh {
+		d.draw()
+	}
+}
+
+func (d *dashboard) draw() {
+	d.drawn = time.Now()
+
+	var b strings.Builder
+
+	// Move to the top left and clear the screen
+	b.WriteString("\u001B[H\u001B[2J")
+
+	fmt.Fprintf(&b, "httping %s (running for %s, interrupt to stop)\n\n", strings.Join(targetUrls, " "), time.Since(d.started).Round(time.Second))
+
+	var failedPct float64
+
+	if d.requests > 0 {
+		failedPct = float64(d.failed) / float64(d.requests) * 100
+	}
+
+	fmt.Fprintf(&b, "Requests: %d   Successful: %s%d%s   Failed: %s%d (%.1f%%)%s\n\n",
+		d.requests, color(green), d.requests-d.failed, color(reset), color(red), d.failed, failedPct, color(reset))
+
+	var window []float64
+
+	for _, latency := range d.latencies {
+		if latency != nil {
+			window = append(window, *latency)
+		}
+	}
+
+	graph := d.latencies[max(len(d.latencies)-dashboardGraphWidth, 0):]
+
+	if len(window) > 0 {
+		s := calculateStats(window)
+
+		fmt.Fprintf(&b, "Last %d requests: min %.1fms  avg %.1fms  max %.1fms  p50 %.1fms  p90 %.1fms  p99 %.1fms\n\n",
+			len(d.latencies), s.Min, s.Average, s.Max, s.Percentile50, s.Percentile90, s.Percentile99)
+
+		fmt.Fprintf(&b, "Latency of the last %d requests (%.1fms - %.1fms, x is a failed request):\n", len(graph), s.Min, s.Max)
+		b.WriteString(sparkline(graph, s.Min, s.Max))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("Recent requests:\n")
+
+	for _, line := range d.lines {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+
+	fmt.Fprint(d.w, b.String())
+}
+
+// sparkline draws the latencies as bars scaled between low and high, failed requests are drawn as a red x
+func sparkline(latencies []*float64, low, high float64) string {
+	var b strings.Builder
+
+	for _, latency := range latencies {
+		if latency == nil {
+			b.WriteString(color(red) + "x" + color(reset))
+			continue
+		}
+
+		i := 0
+
+		if high > low {
+			i = int((*latency - low) / (high - low) * float64(len(sparkBlocks)-1))
+		}
+
+		fmt.Fprintf(&b, "%s%c%s", color(latencyColor(time.Duration(*latency*float64(time.Millisecond)))), sparkBlocks[i], color(reset))
+	}
+
+	return b.String()
+}